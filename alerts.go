@@ -0,0 +1,221 @@
+package dnsmonitor
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Alert describes a status transition of a check as seen from one server
+type Alert struct {
+	CheckID  string      `json:"check"`
+	Domain   string      `json:"domain"`
+	Type     string      `json:"type"`
+	Expected string      `json:"expected"`
+	Previous string      `json:"previous"`
+	Current  string      `json:"current"`
+	Result   CheckResult `json:"result"`
+
+	// Description and Runbook are the check's, to give whoever is paged context
+	Description string `json:"description,omitempty"`
+	Runbook     string `json:"runbook,omitempty"`
+
+	// Event is "failed", "recovered", "propagated" or "changed" for other
+	// transitions, or "slo_burn" and "slo_recovered" when the check's error
+	// budget burn rate crosses its SLO threshold
+	Event string `json:"event"`
+	// Outage is how long the check was failing, set on recovery
+	Outage time.Duration `json:"outage,omitempty"`
+	// Propagation is how long the expected value took to reach every server,
+	// set when a propagation check becomes PROPAGATED
+	Propagation time.Duration `json:"propagation,omitempty"`
+	// BurnRate and SLO are set on SLO alerts
+	BurnRate float64    `json:"burn_rate,omitempty"`
+	SLO      *SLOConfig `json:"slo,omitempty"`
+}
+
+// notifier delivers alerts to one destination. send may block; it is always
+// called from its own goroutine.
+type notifier interface {
+	name() string
+	wants(alert Alert) bool
+	send(alert Alert) error
+}
+
+// failingClass reports whether a status class should page someone
+func failingClass(class string) bool {
+	switch class {
+	case "FAIL", "ERROR", "TIMEOUT", "CERT", "NXDOMAIN", "BOGUS":
+		return true
+	}
+	return false
+}
+
+// Failed reports whether the check started failing with this result
+func (a Alert) Failed() bool {
+	return !failingClass(a.Previous) && failingClass(a.Current)
+}
+
+// Recovered reports whether a failing check passes again with this result
+func (a Alert) Recovered() bool {
+	return failingClass(a.Previous) && a.Current == "PASS"
+}
+
+// Propagated reports whether a propagation check's expected value has just
+// reached every server
+func (a Alert) Propagated() bool {
+	return a.Previous == "PROPAGATING" && a.Current == "PROPAGATED"
+}
+
+// SLOBurning reports whether the check has started burning its error budget
+// faster than its SLO allows
+func (a Alert) SLOBurning() bool {
+	return a.Event == "slo_burn"
+}
+
+// SLORecovered reports whether the check's burn rate is back under its SLO
+// threshold
+func (a Alert) SLORecovered() bool {
+	return a.Event == "slo_recovered"
+}
+
+// Summary is a one-line description used as an email subject or chat headline
+func (a Alert) Summary() string {
+	if a.SLOBurning() {
+		return fmt.Sprintf("%s %s SLO error budget burning at %.1fx (threshold %.1fx)", a.Domain, a.Type, a.BurnRate, a.SLO.BurnRate)
+	}
+	if a.SLORecovered() {
+		return fmt.Sprintf("%s %s SLO burn rate recovered to %.1fx (threshold %.1fx)", a.Domain, a.Type, a.BurnRate, a.SLO.BurnRate)
+	}
+	if a.Recovered() {
+		return fmt.Sprintf("%s %s recovered on %s after %s (was %s)", a.Domain, a.Type, a.Result.Server, a.Outage, a.Previous)
+	}
+	if a.Propagated() {
+		return fmt.Sprintf("%s %s propagated to %s in %s", a.Domain, a.Type, a.Result.Server, a.Propagation)
+	}
+	return fmt.Sprintf("%s %s %s on %s (was %s)", a.Domain, a.Type, a.Current, a.Result.Server, a.Previous)
+}
+
+// Details is a plain-text body with everything needed to triage the alert
+func (a Alert) Details() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Check:    %s\n", a.CheckID)
+	fmt.Fprintf(&b, "Domain:   %s\n", a.Domain)
+	fmt.Fprintf(&b, "Type:     %s\n", a.Type)
+	fmt.Fprintf(&b, "Expected: %s\n", a.Expected)
+	fmt.Fprintf(&b, "Actual:   %s\n", strings.Join(a.Result.ActualResult, ", "))
+	fmt.Fprintf(&b, "Server:   %s\n", a.Result.Server)
+	fmt.Fprintf(&b, "Status:   %s (was %s)\n", a.Result.Describe(), a.Previous)
+	if a.Recovered() {
+		fmt.Fprintf(&b, "Outage:   %s\n", a.Outage)
+	}
+	if a.Propagated() {
+		fmt.Fprintf(&b, "Took:     %s\n", a.Propagation)
+	}
+	if a.SLO != nil {
+		fmt.Fprintf(&b, "SLO:      %.2f%% over %s, burn rate %.1fx (threshold %.1fx)\n", a.SLO.Target, a.SLO.Window, a.BurnRate, a.SLO.BurnRate)
+	}
+	fmt.Fprintf(&b, "Time:     %s\n", a.Result.Timestamp.Format(time.RFC3339))
+	if a.Runbook != "" {
+		fmt.Fprintf(&b, "Runbook:  %s\n", a.Runbook)
+	}
+	if a.Description != "" {
+		fmt.Fprintf(&b, "\n%s\n", a.Description)
+	}
+	return b.String()
+}
+
+// transition builds an alert when the result changes the check's status class
+// for its server. Callers must hold check.historyLock and call it before the
+// result is appended to the history.
+func transition(check *DNSCheck, result CheckResult) (Alert, bool) {
+	for i := len(check.History) - 1; i >= 0; i-- {
+		previous := check.History[i]
+		if previous.Server != result.Server {
+			continue
+		}
+		alert := Alert{
+			CheckID:     check.ID(),
+			Domain:      check.Domain,
+			Type:        check.Type,
+			Expected:    check.Expected,
+			Previous:    statusClass(previous.Status),
+			Current:     statusClass(result.Status),
+			Result:      result,
+			Event:       "changed",
+			Description: check.Description,
+			Runbook:     check.Runbook,
+		}
+		switch {
+		case alert.Failed():
+			alert.Event = "failed"
+		case alert.Recovered():
+			alert.Event = "recovered"
+			alert.Outage = result.Timestamp.Sub(runStart(check.History[:i+1], result.Server, failingClass))
+		case alert.Propagated():
+			alert.Event = "propagated"
+			alert.Propagation = result.Timestamp.Sub(runStart(check.History[:i+1], result.Server, func(class string) bool {
+				return class == "PROPAGATING"
+			})).Round(time.Second)
+		}
+		return alert, alert.Previous != alert.Current
+	}
+	return Alert{}, false
+}
+
+// runStart returns when the server's current run of results whose status class
+// satisfies in began, or the zero time if its latest result is not one of them
+func runStart(history []CheckResult, server string, in func(class string) bool) time.Time {
+	var start time.Time
+	for i := len(history) - 1; i >= 0; i-- {
+		result := history[i]
+		if result.Server != server {
+			continue
+		}
+		if !in(statusClass(result.Status)) {
+			break
+		}
+		start = result.Timestamp
+	}
+	return start
+}
+
+// notify hands an alert to every interested notifier without blocking the caller
+func (c *Config) notify(alert Alert) {
+	for _, n := range c.notifiers {
+		if !n.wants(alert) {
+			continue
+		}
+		c.notifications.Add(1)
+		go func(n notifier) {
+			defer c.notifications.Done()
+			if err := n.send(alert); err != nil {
+				log.Printf("Error sending %s alert for %s: %v", n.name(), alert.CheckID, err)
+			}
+		}(n)
+	}
+}
+
+// cooldown suppresses repeated alerts for the same key within a period
+type cooldown struct {
+	mu     sync.Mutex
+	period time.Duration
+	last   map[string]time.Time
+}
+
+func newCooldown(period time.Duration) *cooldown {
+	return &cooldown{period: period, last: make(map[string]time.Time)}
+}
+
+func (c *cooldown) allow(key string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if last, ok := c.last[key]; ok && now.Sub(last) < c.period {
+		return false
+	}
+	c.last[key] = now
+	return true
+}
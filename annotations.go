@@ -0,0 +1,134 @@
+package dnsmonitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Annotation struct {
+	Timestamp time.Time `json:"timestamp"`
+	Note      string    `json:"note"`
+}
+
+func annotationFile(logDir string, check *DNSCheck) string {
+	return filepath.Join(logDir, check.ID()+".notes")
+}
+
+func saveAnnotation(check *DNSCheck, logDir string, annotation Annotation) error {
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return fmt.Errorf("error creating log directory: %v", err)
+	}
+
+	f, err := os.OpenFile(annotationFile(logDir, check), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening annotation file: %v", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("Error closing annotation file: %v", err)
+		}
+	}()
+
+	// Notes are stored one per line, so flatten any line breaks or tabs
+	note := strings.Join(strings.Fields(annotation.Note), " ")
+	if _, err := fmt.Fprintf(f, "%s\t%s\n", annotation.Timestamp.Format(time.RFC3339), note); err != nil {
+		return fmt.Errorf("error writing annotation file: %v", err)
+	}
+	return nil
+}
+
+func loadAnnotations(check *DNSCheck, file string, retention time.Duration) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("error reading annotation file %s: %v", file, err)
+	}
+
+	check.historyLock.Lock()
+	defer check.historyLock.Unlock()
+
+	cutoff := time.Now().Add(-retention)
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(line, "\t", 2)
+		if len(parts) < 2 {
+			continue
+		}
+
+		timestamp, err := time.Parse(time.RFC3339, parts[0])
+		if err != nil {
+			log.Printf("Error parsing timestamp in annotation file %s: %v", file, err)
+			continue
+		}
+
+		if timestamp.After(cutoff) {
+			check.Annotations = append(check.Annotations, Annotation{Timestamp: timestamp, Note: parts[1]})
+		}
+	}
+	return nil
+}
+
+// annotateHandler serves POST /api/annotate?name=... (or ?domain=...&type=...)
+// with a "note" and optional RFC3339 "timestamp" form value
+func annotateHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if config.Global.APIToken == "" {
+			http.Error(w, "annotations are disabled: api_token is not configured", http.StatusForbidden)
+			return
+		}
+		if !config.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		note := strings.TrimSpace(r.FormValue("note"))
+		if note == "" {
+			http.Error(w, "missing note", http.StatusBadRequest)
+			return
+		}
+
+		annotation := Annotation{Timestamp: time.Now(), Note: note}
+		if ts := r.FormValue("timestamp"); ts != "" {
+			timestamp, err := time.Parse(time.RFC3339, ts)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid timestamp: %v", err), http.StatusBadRequest)
+				return
+			}
+			annotation.Timestamp = timestamp
+		}
+
+		config.mu.RLock()
+		defer config.mu.RUnlock()
+
+		check, err := config.lookupCheck(r)
+		if err != nil {
+			http.Error(w, err.Error(), err.status)
+			return
+		}
+
+		if err := saveAnnotation(check, config.Global.LogDir, annotation); err != nil {
+			log.Printf("Error saving annotation for %s: %v", check.ID(), err)
+			http.Error(w, "failed to save annotation", http.StatusInternalServerError)
+			return
+		}
+
+		check.historyLock.Lock()
+		check.Annotations = append(check.Annotations, annotation)
+		check.historyLock.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(annotation); err != nil {
+			log.Printf("Error encoding annotation response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package dnsmonitor
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type AuthConfig struct {
+	Username       string `yaml:"username"`
+	PasswordSHA256 string `yaml:"password_sha256"`
+	Token          string `yaml:"token"`
+}
+
+func (a AuthConfig) enabled() bool {
+	return a.Username != "" || a.Token != ""
+}
+
+// validate checks that basic auth has both a username and a well-formed password hash
+func (a AuthConfig) validate() error {
+	if a.Username == "" && a.PasswordSHA256 == "" {
+		return nil
+	}
+	if a.Username == "" || a.PasswordSHA256 == "" {
+		return fmt.Errorf("basic auth needs both username and password_sha256")
+	}
+	if hash, err := hex.DecodeString(a.PasswordSHA256); err != nil || len(hash) != sha256.Size {
+		return fmt.Errorf("password_sha256 must be a hex-encoded SHA-256 hash")
+	}
+	return nil
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	return strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// tokenMatches compares tokens in constant time; an unset token never matches
+func tokenMatches(token, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+// allowed reports whether the request carries the configured bearer token or
+// basic auth credentials. The write API's api_token is accepted as well so
+// those endpoints stay usable with basic auth enabled.
+func (c *Config) allowed(r *http.Request) bool {
+	auth := c.Global.Auth
+	if token, ok := bearerToken(r); ok {
+		return tokenMatches(token, auth.Token) || tokenMatches(token, c.Global.APIToken)
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok || auth.Username == "" {
+		return false
+	}
+	want, _ := hex.DecodeString(auth.PasswordSHA256)
+	got := sha256.Sum256([]byte(password))
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(auth.Username)) == 1
+	passOK := subtle.ConstantTimeCompare(got[:], want) == 1
+	return userOK && passOK
+}
+
+// authorized reports whether the request carries the write API's api_token as
+// a bearer token. The read-only auth token and basic auth are not enough.
+func (c *Config) authorized(r *http.Request) bool {
+	token, ok := bearerToken(r)
+	return ok && tokenMatches(token, c.Global.APIToken)
+}
+
+// requireAuth rejects requests without valid credentials when auth is
+// configured. /healthz stays open for load balancer and orchestrator probes.
+func (c *Config) requireAuth(next http.Handler) http.Handler {
+	if !c.Global.Auth.enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || c.allowed(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if c.Global.Auth.Username != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="dns-monitor", charset="UTF-8"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
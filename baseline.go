@@ -0,0 +1,165 @@
+package dnsmonitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func baselineFile(logDir string, check *DNSCheck) string {
+	return filepath.Join(logDir, check.ID()+".baseline")
+}
+
+// saveBaseline stores a check's baseline record set, one record per line,
+// replacing any previous baseline
+func saveBaseline(check *DNSCheck, logDir string, records []string) error {
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return fmt.Errorf("error creating log directory: %v", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a partial baseline
+	file := baselineFile(logDir, check)
+	var data strings.Builder
+	for _, record := range records {
+		data.WriteString(record + "\n")
+	}
+	if err := os.WriteFile(file+".tmp", []byte(data.String()), 0644); err != nil {
+		return fmt.Errorf("error writing baseline file: %v", err)
+	}
+	if err := os.Rename(file+".tmp", file); err != nil {
+		return fmt.Errorf("error replacing baseline file: %v", err)
+	}
+	return nil
+}
+
+func loadBaseline(check *DNSCheck, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("error reading baseline file %s: %v", file, err)
+	}
+
+	records := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			records = append(records, line)
+		}
+	}
+
+	check.historyLock.Lock()
+	check.baseline = records
+	check.historyLock.Unlock()
+	return nil
+}
+
+// baselineRecords returns the captured baseline, or false if none has been
+// captured yet
+func (check *DNSCheck) baselineRecords() ([]string, bool) {
+	check.historyLock.RLock()
+	defer check.historyLock.RUnlock()
+	return check.baseline, check.baseline != nil
+}
+
+// captureBaseline makes records the check's new baseline and persists it
+func (c *Config) captureBaseline(check *DNSCheck, records []string) error {
+	baseline := append([]string{}, records...)
+	if err := saveBaseline(check, c.Global.LogDir, baseline); err != nil {
+		return err
+	}
+	check.historyLock.Lock()
+	check.baseline = baseline
+	check.historyLock.Unlock()
+	return nil
+}
+
+// baselineDiff describes how records differ from the baseline, listing added
+// records with "+" and removed ones with "-"
+func baselineDiff(records, baseline []string) string {
+	normalize := func(records []string) map[string]bool {
+		set := make(map[string]bool)
+		for _, record := range records {
+			set[normalizeRecord(record)] = true
+		}
+		return set
+	}
+
+	current, previous := normalize(records), normalize(baseline)
+	var changes []string
+	for record := range current {
+		if !previous[record] {
+			changes = append(changes, "+"+record)
+		}
+	}
+	for record := range previous {
+		if !current[record] {
+			changes = append(changes, "-"+record)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i][1:] < changes[j][1:] })
+	return strings.Join(changes, ",")
+}
+
+// baselineHandler serves POST /api/baseline?name=... (or ?domain=...&type=...),
+// recapturing a baseline check's baseline from its latest answer
+func baselineHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if config.Global.APIToken == "" {
+			http.Error(w, "baseline capture is disabled: api_token is not configured", http.StatusForbidden)
+			return
+		}
+		if !config.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		config.mu.RLock()
+		defer config.mu.RUnlock()
+
+		check, err := config.lookupCheck(r)
+		if err != nil {
+			http.Error(w, err.Error(), err.status)
+			return
+		}
+		if !check.Baseline {
+			http.Error(w, fmt.Sprintf("check %s does not use a baseline", check.ID()), http.StatusBadRequest)
+			return
+		}
+
+		// Only answers can become a baseline, not errors or server comparisons
+		var records []string
+		found := false
+		check.historyLock.RLock()
+		for i := len(check.History) - 1; i >= 0; i-- {
+			if class := statusClass(check.History[i].Status); class == "PASS" || class == "CHANGED" {
+				records, found = append([]string{}, check.History[i].ActualResult...), true
+				break
+			}
+		}
+		check.historyLock.RUnlock()
+		if !found {
+			http.Error(w, fmt.Sprintf("check %s has no answer to capture yet", check.ID()), http.StatusConflict)
+			return
+		}
+
+		if err := config.captureBaseline(check, records); err != nil {
+			log.Printf("Error saving baseline for %s: %v", check.ID(), err)
+			http.Error(w, "failed to save baseline", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Recaptured baseline for %s: %s", check.ID(), strings.Join(records, ","))
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string][]string{"baseline": records}); err != nil {
+			log.Printf("Error encoding baseline response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package dnsmonitor
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+)
+
+// lookupCAA queries a name's CAA records directly from the server, since
+// net.Resolver has no CAA lookup. A name without CAA records is not an error:
+// any CA may issue for it.
+func lookupCAA(ctx context.Context, domain, server string, forceTCP bool) ([]*dns.CAA, error) {
+	resp, err := queryDirect(ctx, domain, dns.TypeCAA, server, forceTCP)
+	if err != nil {
+		return nil, err
+	}
+	var records []*dns.CAA
+	for _, rr := range resp.Answer {
+		if caa, ok := rr.(*dns.CAA); ok {
+			records = append(records, caa)
+		}
+	}
+	return records, nil
+}
+
+func (r netResolver) LookupCAA(ctx context.Context, name string) ([]*dns.CAA, error) {
+	return lookupCAA(ctx, name, r.server, r.forceTCP)
+}
+
+func (r *wireResolver) LookupCAA(ctx context.Context, name string) ([]*dns.CAA, error) {
+	rrs, err := r.query(ctx, name, dns.TypeCAA)
+	if err != nil {
+		return nil, err
+	}
+	var records []*dns.CAA
+	for _, rr := range rrs {
+		if caa, ok := rr.(*dns.CAA); ok {
+			records = append(records, caa)
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,106 @@
+package dnsmonitor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxChainHops bounds how many times resolveChain asks again for the target of
+// a chain that a server answered only in part
+const maxChainHops = 8
+
+// resolveChain follows a name's CNAME and DNAME records to its canonical name
+// and resolves that name's A and AAAA records, returning a description of each
+// hop and the terminal addresses. Provider-side ALIAS/ANAME records are
+// flattened by the server and show up as addresses without hops. Servers that
+// answer with only part of the chain, such as the authoritative server of one
+// of its zones, are asked again for the last target.
+func resolveChain(ctx context.Context, resolver Resolver, server, domain string) ([]string, []string, error) {
+	var hops, terminal []string
+	name := dns.Fqdn(domain)
+	seen := map[string]bool{}
+	for {
+		if seen[strings.ToLower(name)] {
+			return nil, nil, fmt.Errorf("alias loop at %s", name)
+		}
+		if len(seen) > maxChainHops {
+			return nil, nil, fmt.Errorf("alias chain of %s is longer than %d hops", domain, maxChainHops)
+		}
+		seen[strings.ToLower(name)] = true
+
+		answer, err := chainQuery(ctx, resolver, server, name, dns.TypeA)
+		if err != nil {
+			return nil, nil, err
+		}
+		canonical, aliases, records, addresses := walkChain(name, answer)
+		hops = append(append(hops, aliases...), records...)
+		terminal = append(terminal, addresses...)
+		if len(addresses) == 0 && !strings.EqualFold(canonical, name) {
+			// The server stopped at an alias; resolve its target next
+			name = canonical
+			continue
+		}
+
+		answer, err = chainQuery(ctx, resolver, server, canonical, dns.TypeAAAA)
+		if err != nil {
+			return nil, nil, err
+		}
+		// The aliases leading to the canonical name were recorded with the A answer
+		_, _, records, addresses = walkChain(canonical, answer)
+		hops = append(hops, records...)
+		terminal = append(terminal, addresses...)
+		if len(terminal) == 0 {
+			return nil, nil, &net.DNSError{Err: "no such host", Name: domain, Server: server, IsNotFound: true}
+		}
+		return hops, terminal, nil
+	}
+}
+
+// chainQuery sends one query of a CHAIN check and returns its answer section
+func chainQuery(ctx context.Context, resolver Resolver, server, name string, qtype uint16) ([]dns.RR, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	resp, err := resolver.Exchange(ctx, msg)
+	if err != nil {
+		return nil, exchangeError(err, strings.TrimSuffix(name, "."), server)
+	}
+	if err := rcodeErr(resp.Rcode, strings.TrimSuffix(name, "."), server); err != nil {
+		return nil, err
+	}
+	return resp.Answer, nil
+}
+
+// walkChain follows the aliases in an answer from name, returning the last name
+// reached, a description of each alias on the way and of that name's address
+// records, and the addresses themselves
+func walkChain(name string, answer []dns.RR) (canonical string, aliases, records, addresses []string) {
+	for _, rr := range answer {
+		switch rr := rr.(type) {
+		case *dns.DNAME:
+			// The CNAME synthesized from it follows and moves the chain on
+			if dns.IsSubDomain(rr.Hdr.Name, name) {
+				aliases = append(aliases, fmt.Sprintf("%s DNAME %s", rr.Hdr.Name, rr.Target))
+			}
+		case *dns.CNAME:
+			if strings.EqualFold(rr.Hdr.Name, name) {
+				aliases = append(aliases, fmt.Sprintf("%s CNAME %s", rr.Hdr.Name, rr.Target))
+				name = rr.Target
+			}
+		case *dns.A:
+			if strings.EqualFold(rr.Hdr.Name, name) {
+				records = append(records, fmt.Sprintf("%s A %s", rr.Hdr.Name, rr.A))
+				addresses = append(addresses, rr.A.String())
+			}
+		case *dns.AAAA:
+			if strings.EqualFold(rr.Hdr.Name, name) {
+				records = append(records, fmt.Sprintf("%s AAAA %s", rr.Hdr.Name, rr.AAAA))
+				addresses = append(addresses, rr.AAAA.String())
+			}
+		}
+	}
+	return name, aliases, records, addresses
+}
@@ -0,0 +1,205 @@
+package dnsmonitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxCheckBody limits the size of a check definition sent to the API
+const maxCheckBody = 64 << 10
+
+// createCheckHandler serves POST /api/checks: it validates a check sent as
+// JSON with the same fields as a check in config.yaml, starts monitoring it
+// and returns its state. Checks added this way last until the process exits
+// and survive config reloads unless the file defines a check with the same ID.
+func createCheckHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if config.Global.APIToken == "" {
+			http.Error(w, "adding checks is disabled: api_token is not configured", http.StatusForbidden)
+			return
+		}
+		if !config.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !config.monitoring.Load() {
+			http.Error(w, "monitoring not running", http.StatusServiceUnavailable)
+			return
+		}
+
+		// JSON is valid YAML, so decoding with the config's yaml tags keeps the
+		// field names and duration syntax ("5m") the same as in config.yaml
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCheckBody))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error reading check: %v", err), http.StatusBadRequest)
+			return
+		}
+		check := new(DNSCheck)
+		decoder := yaml.NewDecoder(bytes.NewReader(body))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(check); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, fmt.Sprintf("invalid check: %v", err), http.StatusBadRequest)
+			return
+		}
+		if check.Template != "" {
+			if check, err = config.templatedCheck(body); err != nil {
+				http.Error(w, fmt.Sprintf("invalid check: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		// Commands can only come from whoever controls the config file
+		if check.Type == "EXEC" {
+			http.Error(w, "invalid check: EXEC checks can only be defined in the config file", http.StatusBadRequest)
+			return
+		}
+		if len(check.Subdomains) > 0 {
+			http.Error(w, "invalid check: subdomains are not supported here; add one check per name", http.StatusBadRequest)
+			return
+		}
+		if errs := validateCheck(check); len(errs) > 0 {
+			http.Error(w, fmt.Sprintf("invalid check: %v", errors.Join(errs...)), http.StatusBadRequest)
+			return
+		}
+
+		config.mu.Lock()
+		defer config.mu.Unlock()
+
+		for _, existing := range config.Checks {
+			if existing.ID() == check.ID() {
+				http.Error(w, fmt.Sprintf("check %q already exists; give it a unique name", check.ID()), http.StatusConflict)
+				return
+			}
+		}
+		if err := config.initCheck(check); err != nil {
+			http.Error(w, fmt.Sprintf("invalid check: %v", err), http.StatusBadRequest)
+			return
+		}
+		check.Runtime = true
+		config.Checks = append(config.Checks, check)
+		config.startMonitor(check)
+		log.Printf("Added check %s via the API", check.ID())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(config.checkStatus(check)); err != nil {
+			log.Printf("Error encoding check response: %v", err)
+		}
+	}
+}
+
+// templatedCheck decodes a check sent to the API that names a template, with
+// the template's fields merged in
+func (c *Config) templatedCheck(body []byte) (*DNSCheck, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	merged, err := applyTemplate(documentRoot(&doc), c.templates)
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	check := new(DNSCheck)
+	if err := merged.Decode(check); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// deleteCheckHandler serves DELETE /api/checks/{id} and, for checks that do not
+// share their domain and type, DELETE /api/checks/{domain}/{type}: it stops
+// polling the check and drops it from the status page and API. Its log,
+// annotations and baseline stay on disk unless ?logs=delete removes them or
+// ?logs=archive moves them to an archive directory under log_dir.
+func deleteCheckHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if config.Global.APIToken == "" {
+			http.Error(w, "removing checks is disabled: api_token is not configured", http.StatusForbidden)
+			return
+		}
+		if !config.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !config.monitoring.Load() {
+			http.Error(w, "monitoring not running", http.StatusServiceUnavailable)
+			return
+		}
+		logs := r.URL.Query().Get("logs")
+		if logs != "" && logs != "keep" && logs != "delete" && logs != "archive" {
+			http.Error(w, fmt.Sprintf("invalid logs %q: want keep, delete or archive", logs), http.StatusBadRequest)
+			return
+		}
+
+		config.mu.Lock()
+		check, err := config.lookupCheck(r)
+		if err != nil {
+			config.mu.Unlock()
+			http.Error(w, err.Error(), err.status)
+			return
+		}
+		// Under the write lock, so neither updateStatus nor a page being
+		// rendered sees the check half removed
+		config.stopMonitor(check)
+		config.Checks = slices.DeleteFunc(slices.Clone(config.Checks), func(c *DNSCheck) bool { return c == check })
+		config.mu.Unlock()
+		log.Printf("Removed check %s via the API", check.ID())
+
+		if logs == "delete" || logs == "archive" {
+			if err := config.removeCheckFiles(check, logs == "archive"); err != nil {
+				log.Printf("Error removing files for %s: %v", check.ID(), err)
+				http.Error(w, fmt.Sprintf("check removed, but %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// removeCheckFiles deletes a removed check's history log, rotated copies,
+// annotations and baseline, or moves them to log_dir/archive/<id>-<time>/
+func (c *Config) removeCheckFiles(check *DNSCheck, archive bool) error {
+	// Writes already under way finish first; later ones see the check is removed
+	check.logLock.Lock()
+	defer check.logLock.Unlock()
+
+	files := historyLogFiles(historyLogFile(c.Global.LogDir, check))
+	for _, file := range []string{annotationFile(c.Global.LogDir, check), baselineFile(c.Global.LogDir, check)} {
+		if _, err := os.Stat(file); err == nil {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	var dir string
+	if archive {
+		dir = filepath.Join(c.Global.LogDir, "archive", check.ID()+"-"+time.Now().UTC().Format("20060102T150405Z"))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error creating archive directory: %v", err)
+		}
+	}
+	for _, file := range files {
+		if archive {
+			if err := os.Rename(file, filepath.Join(dir, filepath.Base(file))); err != nil {
+				return fmt.Errorf("error archiving %s: %v", file, err)
+			}
+		} else if err := os.Remove(file); err != nil {
+			return fmt.Errorf("error deleting %s: %v", file, err)
+		}
+	}
+	return nil
+}
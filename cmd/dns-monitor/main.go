@@ -0,0 +1,160 @@
+// Command dns-monitor monitors DNS records and serves their status over HTTP.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	dnsmonitor "github.com/RickBrewer/dns-monitor"
+)
+
+func main() {
+	// Keep recent errors and warnings for the diagnostics panel
+	log.SetOutput(io.MultiWriter(os.Stderr, dnsmonitor.Diagnostics()))
+
+	configFile := flag.String("config", "config.yaml", "path to the YAML config file")
+	port := flag.String("port", "", "web interface port, overrides global.listen_addr and global.port")
+	listenAddr := flag.String("listen-addr", "", "web interface listen address (host:port), overrides -port, global.listen_addr and global.port")
+	initConfig := flag.Bool("init", false, "write a commented sample config to the -config path and exit")
+	once := flag.Bool("once", false, "run every check once, print a summary and exit non-zero unless all pass")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n\nMonitors DNS records and serves their status over HTTP.\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *initConfig {
+		if err := dnsmonitor.WriteSampleConfig(*configFile); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Wrote a sample config to %s. Edit the DNS servers and checks, then run %s -config %s\n", *configFile, os.Args[0], *configFile)
+		return
+	}
+
+	// Give new users a commented config to start from instead of just failing
+	if _, err := os.Stat(*configFile); errors.Is(err, os.ErrNotExist) {
+		if err := dnsmonitor.WriteSampleConfig(*configFile); err != nil {
+			log.Fatalf("Config file %s not found, and %v", *configFile, err)
+		}
+		log.Fatalf("Config file %s not found, so a commented sample was written there. Edit the DNS servers and checks to monitor, then start again.", *configFile)
+	} else if err != nil {
+		log.Fatalf("Config file %s not readable: %v", *configFile, err)
+	}
+
+	// Stop loading, monitoring and serving on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	config, err := dnsmonitor.LoadConfigContext(ctx, *configFile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// One-shot validation, e.g. to gate a deployment in CI
+	if *once {
+		failed := dnsmonitor.WriteRunSummary(os.Stdout, config.RunOnce(ctx))
+		if ctx.Err() != nil {
+			log.Fatalf("Interrupted before every check ran")
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Command-line flags take precedence over the config file
+	switch {
+	case *listenAddr != "":
+		err = config.SetListenAddr(*listenAddr)
+	case *port != "":
+		err = config.SetListenAddr(":" + strings.TrimPrefix(*port, ":"))
+	}
+	if err != nil {
+		log.Fatalf("Invalid listen address: %v", err)
+	}
+
+	if err := config.Setup(); err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
+
+	// Bind before monitoring starts, so an address in use fails straight away
+	listener, err := net.Listen("tcp", config.ListenAddr())
+	if err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
+	var redirectListener net.Listener
+	if addr := config.Global.HTTPRedirectAddr; addr != "" {
+		if redirectListener, err = net.Listen("tcp", addr); err != nil {
+			log.Fatalf("Failed to start: %v", err)
+		}
+	}
+
+	// Start DNS monitoring in background
+	monitoring := make(chan struct{})
+	go func() {
+		config.Monitor(ctx)
+		close(monitoring)
+	}()
+	go config.ReloadOnSIGHUP(*configFile)
+
+	handler, err := config.Handler(ctx)
+	if err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
+
+	// Start web server
+	server := &http.Server{Handler: handler, TLSConfig: config.TLSConfig()}
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			log.Printf("Starting server on %s with TLS", listener.Addr())
+			err = server.ServeTLS(listener, "", "")
+		} else {
+			log.Printf("Starting server on %s", listener.Addr())
+			err = server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	// Send plain HTTP visitors to the HTTPS address
+	redirect := &http.Server{Handler: config.RedirectHandler()}
+	if redirectListener != nil {
+		go func() {
+			log.Printf("Redirecting HTTP on %s to HTTPS", redirectListener.Addr())
+			if err := redirect.Serve(redirectListener); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Redirect server failed: %v", err)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	log.Printf("Shutting down")
+
+	// Keep serving while in-flight checks finish, so probes see /healthz go 503
+	<-monitoring
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down HTTP server: %v", err)
+	}
+	if redirectListener != nil {
+		redirect.Shutdown(shutdownCtx)
+	}
+
+	// Let log lines and alerts from the last checks go out
+	config.Shutdown(shutdownCtx)
+}
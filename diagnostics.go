@@ -0,0 +1,84 @@
+package dnsmonitor
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type DiagnosticEvent struct {
+	Time    time.Time
+	Level   string
+	Message string
+}
+
+// eventRing keeps the most recent errors and warnings written to the standard
+// logger so they can be shown on the status page
+type eventRing struct {
+	mu     sync.Mutex
+	events []DiagnosticEvent
+	next   int
+	full   bool
+}
+
+var diagnostics = newEventRing(100)
+
+func newEventRing(size int) *eventRing {
+	return &eventRing{events: make([]DiagnosticEvent, size)}
+}
+
+// Write implements io.Writer so the ring can be attached to the standard logger.
+// Only lines that look like errors or warnings are kept.
+func (r *eventRing) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+
+	// Strip the logger's "2006/01/02 15:04:05 " prefix, the time is recorded separately
+	if parts := strings.SplitN(msg, " ", 3); len(parts) == 3 && strings.Count(parts[0], "/") == 2 {
+		msg = parts[2]
+	}
+
+	lower := strings.ToLower(msg)
+	level := ""
+	switch {
+	case strings.HasPrefix(lower, "warning"):
+		level = "warning"
+	case strings.Contains(lower, "error") || strings.Contains(lower, "failed"):
+		level = "error"
+	case strings.Contains(lower, "warning"):
+		level = "warning"
+	default:
+		return len(p), nil
+	}
+
+	r.mu.Lock()
+	r.events[r.next] = DiagnosticEvent{Time: time.Now(), Level: level, Message: msg}
+	r.next = (r.next + 1) % len(r.events)
+	if r.next == 0 {
+		r.full = true
+	}
+	r.mu.Unlock()
+
+	return len(p), nil
+}
+
+// snapshot returns the retained events, newest first
+func (r *eventRing) snapshot() []DiagnosticEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := r.next
+	if r.full {
+		count = len(r.events)
+	}
+
+	out := make([]DiagnosticEvent, 0, count)
+	for i := 1; i <= count; i++ {
+		out = append(out, r.events[(r.next-i+len(r.events))%len(r.events)])
+	}
+	return out
+}
+
+// Diagnostics exposes recent internal errors and warnings to the status page
+func (c *Config) Diagnostics() []DiagnosticEvent {
+	return diagnostics.snapshot()
+}
@@ -0,0 +1,116 @@
+package dnsmonitor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// addClientSubnet attaches the check's EDNS Client Subnet option to a query,
+// so GeoDNS servers answer as they would for a client in that subnet. Call it
+// after any SetEdns0, which would otherwise replace the option.
+func (check *DNSCheck) addClientSubnet(msg *dns.Msg) {
+	if check.clientSubnet == nil {
+		return
+	}
+	opt := msg.IsEdns0()
+	if opt == nil {
+		msg.SetEdns0(4096, false)
+		opt = msg.IsEdns0()
+	}
+
+	ecs := &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, Address: check.clientSubnet.IP}
+	if check.clientSubnet.IP.To4() == nil {
+		ecs.Family = 2
+	}
+	ones, _ := check.clientSubnet.Mask.Size()
+	ecs.SourceNetmask = uint8(ones)
+	opt.Option = append(opt.Option, ecs)
+}
+
+// queryClass parses a check's class, returning its short name and, for classes
+// other than IN, its code. IN needs no code as it is what queries ask for anyway.
+func queryClass(name string) (string, uint16, bool) {
+	switch strings.ToUpper(name) {
+	case "IN":
+		return "IN", 0, true
+	case "CH", "CHAOS":
+		return "CH", dns.ClassCHAOS, true
+	case "HS", "HESIOD":
+		return "HS", dns.ClassHESIOD, true
+	}
+	return "", 0, false
+}
+
+// setClass puts the check's query class, such as CH for version.bind, on a
+// query built with SetQuestion, which always asks for class IN
+func (check *DNSCheck) setClass(msg *dns.Msg) {
+	if check.qclass != 0 {
+		msg.Question[0].Qclass = check.qclass
+	}
+}
+
+// lookupDirect looks up the check's records with a query of its own, for what
+// net.Resolver cannot do: attach a client subnet, query a class other than IN
+// or report the RCODE. With an expected RCODE every response code is returned
+// as an rcodeError for the caller to judge; otherwise they fail like the
+// regular lookups.
+func lookupDirect(ctx context.Context, check *DNSCheck, resolver Resolver, server string) ([]string, error) {
+	name, qtype := directQuestion(check)
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	check.setClass(msg)
+	check.addClientSubnet(msg)
+
+	resp, err := resolver.Exchange(ctx, msg)
+	if err != nil {
+		return nil, exchangeError(err, check.Domain, server)
+	}
+
+	var records []string
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype != qtype {
+			continue
+		}
+		if value, ok := rrValue(rr); ok {
+			records = append(records, value)
+		}
+	}
+
+	if check.ExpectedRcode != "" {
+		return records, &rcodeError{rcode: resp.Rcode}
+	}
+	if err := rcodeErr(resp.Rcode, check.Domain, server); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: check.Domain, Server: server, IsNotFound: true}
+	}
+	return records, nil
+}
+
+// exchangeError wraps a failed query like net.Resolver's errors, so retries and
+// timeouts are classified the same way as for the regular lookups
+func exchangeError(err error, name, server string) error {
+	var netErr net.Error
+	timeout := errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+	return &net.DNSError{Err: err.Error(), Name: name, Server: server,
+		IsTimeout: timeout, IsTemporary: true, UnwrapErr: err}
+}
+
+// rcodeErr returns the error net.Resolver would report for a response code,
+// or nil for NOERROR
+func rcodeErr(rcode int, name, server string) error {
+	switch rcode {
+	case dns.RcodeSuccess:
+		return nil
+	case dns.RcodeNameError:
+		return &net.DNSError{Err: "no such host", Name: name, Server: server, IsNotFound: true}
+	case dns.RcodeServerFailure:
+		return &net.DNSError{Err: "server misbehaving", Name: name, Server: server, IsTemporary: true}
+	}
+	return &net.DNSError{Err: dns.RcodeToString[rcode], Name: name, Server: server}
+}
@@ -0,0 +1,241 @@
+package dnsmonitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type DiscoverConfig struct {
+	Zone          string        `yaml:"zone"`
+	Server        string        `yaml:"server"`
+	Seeds         []string      `yaml:"seeds"`
+	Types         []string      `yaml:"types"`
+	Deny          []string      `yaml:"deny"`
+	Interval      time.Duration `yaml:"interval"`
+	CheckInterval time.Duration `yaml:"check_interval"`
+}
+
+var discoverableTypes = map[string]bool{"A": true, "CNAME": true, "MX": true, "NS": true, "TXT": true, "SRV": true, "CAA": true}
+
+// validate applies defaults and checks the discovery settings at config load
+func (d *DiscoverConfig) validate(dnsServer string) error {
+	if d.Zone == "" {
+		return fmt.Errorf("discover requires a zone")
+	}
+	if d.Server == "" {
+		d.Server = dnsServer
+	}
+	if d.Server == "" {
+		return fmt.Errorf("discover requires a server (or a global dns_server)")
+	}
+	if _, err := serverAddr(d.Server); err != nil {
+		return fmt.Errorf("invalid discover server: %v", err)
+	}
+	if len(d.Types) == 0 {
+		d.Types = []string{"A", "CNAME", "MX", "NS", "TXT"}
+	}
+	for i, t := range d.Types {
+		d.Types[i] = strings.ToUpper(t)
+		if !discoverableTypes[d.Types[i]] {
+			return fmt.Errorf("discover cannot create checks for record type %s", t)
+		}
+	}
+	for _, pattern := range d.Deny {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid discover deny pattern %q: %v", pattern, err)
+		}
+	}
+	if d.Interval == 0 {
+		d.Interval = time.Hour
+	}
+	return nil
+}
+
+func (d *DiscoverConfig) denied(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, pattern := range d.Deny {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// discoverRecords enumerates the zone via AXFR, or by querying each seed name
+// when seeds are configured, returning record values keyed by zoneKey
+func (d *DiscoverConfig) discoverRecords() (map[string][]string, error) {
+	var rrs []dns.RR
+	zone := dns.Fqdn(d.Zone)
+	server, err := serverAddr(d.Server)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(d.Seeds) == 0 {
+		msg := new(dns.Msg)
+		msg.SetAxfr(zone)
+		envelopes, err := new(dns.Transfer).In(msg, server)
+		if err != nil {
+			return nil, fmt.Errorf("zone transfer failed: %v", err)
+		}
+		for envelope := range envelopes {
+			if envelope.Error != nil {
+				return nil, fmt.Errorf("zone transfer failed: %v", envelope.Error)
+			}
+			rrs = append(rrs, envelope.RR...)
+		}
+	} else {
+		client := &dns.Client{Timeout: 5 * time.Second}
+		for _, seed := range d.Seeds {
+			name := seed
+			switch {
+			case seed == "@":
+				name = zone
+			case !strings.HasSuffix(seed, "."):
+				name = seed + "." + zone
+			}
+
+			for _, t := range d.Types {
+				msg := new(dns.Msg)
+				msg.SetQuestion(dns.Fqdn(name), dns.StringToType[t])
+				resp, _, err := client.Exchange(msg, server)
+				if err != nil {
+					return nil, fmt.Errorf("error querying %s %s: %v", name, t, err)
+				}
+				for _, rr := range resp.Answer {
+					// Skip CNAMEs followed on the way to another type
+					if strings.EqualFold(rr.Header().Name, dns.Fqdn(name)) && rr.Header().Rrtype == dns.StringToType[t] {
+						rrs = append(rrs, rr)
+					}
+				}
+			}
+		}
+	}
+
+	wanted := make(map[string]bool)
+	for _, t := range d.Types {
+		wanted[t] = true
+	}
+
+	records := make(map[string][]string)
+	for _, rr := range rrs {
+		name := rr.Header().Name
+		recordType := dns.TypeToString[rr.Header().Rrtype]
+		if !wanted[recordType] || strings.Contains(name, "*") || d.denied(name) || !dns.IsSubDomain(zone, name) {
+			continue
+		}
+		if value, ok := rrValue(rr); ok {
+			key := zoneKey(name, recordType)
+			records[key] = append(records[key], value)
+		}
+	}
+	return records, nil
+}
+
+// discoveredChecks turns discovered records into checks that expect the
+// currently published record set: the first value must be answered exactly,
+// and an answer that differs from the whole set is reported as DRIFT
+func (d *DiscoverConfig) discoveredChecks(records map[string][]string) []*DNSCheck {
+	var checks []*DNSCheck
+	for key, values := range records {
+		name, recordType, _ := strings.Cut(key, "/")
+		sort.Strings(values)
+		checks = append(checks, &DNSCheck{
+			Domain:      strings.TrimSuffix(name, "."),
+			Type:        recordType,
+			Expected:    values[0],
+			MatchMode:   "exact",
+			Interval:    d.CheckInterval,
+			Discovered:  true,
+			zoneRecords: values,
+		})
+	}
+	sort.Slice(checks, func(i, j int) bool { return checks[i].ID() < checks[j].ID() })
+	return checks
+}
+
+// syncDiscovered merges discovered checks into the running set: manually
+// defined checks win, vanished or changed records stop their checks, and new
+// records start monitoring. History carries over through the log files.
+func (c *Config) syncDiscovered(found []*DNSCheck) (added, removed int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	manual := make(map[string]bool)
+	for _, check := range c.Checks {
+		if !check.Discovered {
+			manual[zoneKey(check.Domain, check.Type)] = true
+		}
+	}
+
+	wanted := make(map[string]*DNSCheck)
+	for _, check := range found {
+		if !manual[zoneKey(check.Domain, check.Type)] {
+			wanted[check.ID()] = check
+		}
+	}
+
+	var kept []*DNSCheck
+	for _, check := range c.Checks {
+		if !check.Discovered {
+			kept = append(kept, check)
+			continue
+		}
+		if w, ok := wanted[check.ID()]; ok && slices.Equal(w.zoneRecords, check.zoneRecords) {
+			kept = append(kept, check)
+			delete(wanted, check.ID())
+			continue
+		}
+		c.stopMonitor(check)
+		removed++
+	}
+
+	for _, check := range found {
+		if wanted[check.ID()] != check {
+			continue
+		}
+		if errs := validateCheck(check); len(errs) > 0 {
+			log.Printf("Error adding discovered check %s: %v", check.ID(), errors.Join(errs...))
+			continue
+		}
+		if err := c.initCheck(check); err != nil {
+			log.Printf("Error adding discovered check %s: %v", check.ID(), err)
+			continue
+		}
+		kept = append(kept, check)
+		c.startMonitor(check)
+		added++
+	}
+
+	c.Checks = kept
+	return added, removed
+}
+
+// runDiscovery periodically rescans the configured zone and syncs the checks
+func (c *Config) runDiscovery() {
+	d := c.Global.Discover
+	for {
+		records, err := d.discoverRecords()
+		if err != nil {
+			log.Printf("Error discovering records in %s: %v", d.Zone, err)
+		} else {
+			found := d.discoveredChecks(records)
+			if added, removed := c.syncDiscovered(found); added > 0 || removed > 0 {
+				log.Printf("Discovered %d checks in %s (%d added, %d removed)", len(found), d.Zone, added, removed)
+			}
+		}
+		select {
+		case <-time.After(d.Interval):
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
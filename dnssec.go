@@ -0,0 +1,67 @@
+package dnsmonitor
+
+import (
+	"context"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+func (r netResolver) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	return exchangeDirect(ctx, msg, r.server, r.forceTCP)
+}
+
+func (r *wireResolver) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	return r.exchange(ctx, msg)
+}
+
+// directQuestion is the name and type asked about when a check queries the
+// server itself, for DNSSEC validation or TTLs
+func directQuestion(check *DNSCheck) (string, uint16) {
+	switch check.Type {
+	case "PTR":
+		name, _ := dns.ReverseAddr(check.Domain)
+		return name, dns.TypePTR
+	case "CHAIN":
+		return dns.Fqdn(check.queryName()), dns.TypeA
+	}
+	return dns.Fqdn(check.queryName()), dns.StringToType[check.Type]
+}
+
+// dnssecStatus asks the server, which must be a validating resolver, whether
+// the check's answer is authenticated. It returns "" when the answer carries
+// the AD bit, "INSECURE" when there is no chain of trust to validate, and
+// "BOGUS" when validation failed. Validating resolvers answer SERVFAIL for
+// bogus data, so a SERVFAIL is retried with checking disabled: if that
+// succeeds, validation was the problem.
+func (check *DNSCheck) dnssecStatus(ctx context.Context, resolver Resolver, server string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	name, qtype := directQuestion(check)
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	msg.SetEdns0(4096, true)
+	msg.AuthenticatedData = true
+	check.addClientSubnet(msg)
+
+	resp, err := resolver.Exchange(ctx, msg)
+	if err != nil {
+		return "", err
+	}
+	if resp.Rcode == dns.RcodeServerFailure {
+		msg.CheckingDisabled = true
+		resp, err = resolver.Exchange(ctx, msg)
+		if err != nil {
+			return "", err
+		}
+		if resp.Rcode == dns.RcodeServerFailure {
+			return "", &net.DNSError{Err: "server misbehaving", Name: check.Domain, Server: server, IsTemporary: true}
+		}
+		return "BOGUS", nil
+	}
+	if !resp.AuthenticatedData {
+		return "INSECURE", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,97 @@
+package dnsmonitor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go/http3"
+)
+
+const dnsMessageType = "application/dns-message"
+
+var (
+	dohClient  = &http.Client{}
+	doh3Client = &http.Client{Transport: &http3.Transport{}}
+)
+
+// dohServer splits a DoH server of the form https://host/path[#h3] into the
+// URL to query and whether to query it over HTTP/3. The fragment is never
+// sent, so it only selects the transport.
+func dohServer(server string) (endpoint string, h3 bool, err error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", false, fmt.Errorf("DoH server %q must be an https:// URL such as https://dns.google/dns-query", server)
+	}
+	if u.Fragment != "" && u.Fragment != "h3" {
+		return "", false, fmt.Errorf("DoH server %q has unknown option #%s; use #h3 to query over HTTP/3", server, u.Fragment)
+	}
+	endpoint, _, _ = strings.Cut(server, "#")
+	return endpoint, u.Fragment == "h3", nil
+}
+
+func validateDoHURL(server string) error {
+	_, _, err := dohServer(server)
+	return err
+}
+
+// newDoHResolver resolves through a DNS-over-HTTPS endpoint (RFC 8484), over
+// HTTP/3 when the server ends in #h3
+func newDoHResolver(server string) *wireResolver {
+	// Servers are validated when the config is loaded
+	endpoint, h3, _ := dohServer(server)
+	return &wireResolver{
+		server: server,
+		exchange: func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+			return dohExchange(ctx, endpoint, h3, msg)
+		},
+	}
+}
+
+// dohExchange POSTs a DNS query in wire format and unpacks the reply
+func dohExchange(ctx context.Context, endpoint string, h3 bool, msg *dns.Msg) (*dns.Msg, error) {
+	// RFC 8484 asks for ID 0 so identical queries are cacheable
+	msg.Id = 0
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("error packing DNS query: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dnsMessageType)
+	req.Header.Set("Accept", dnsMessageType)
+
+	var resp *http.Response
+	if !h3 {
+		resp, err = dohClient.Do(req)
+	} else if resp, err = doh3Client.Do(req); err != nil && ctx.Err() == nil {
+		// No fallback to HTTP/2: the point of #h3 is to test the QUIC path
+		err = fmt.Errorf("error querying over HTTP/3, check the server supports it: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH server returned %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, fmt.Errorf("error reading DoH response: %w", err)
+	}
+
+	reply := new(dns.Msg)
+	if err := reply.Unpack(body); err != nil {
+		return nil, fmt.Errorf("error unpacking DoH response: %v", err)
+	}
+	return reply, nil
+}
@@ -0,0 +1,56 @@
+package dnsmonitor
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// dotServer splits a DoT server of the form host[:port][#tls-name] into the
+// address to dial (port 853 by default) and the name its certificate must match.
+// Without a #tls-name, defaultName is used, falling back to the host itself.
+func dotServer(server, defaultName string) (addr, serverName string, err error) {
+	hostPort, name, _ := strings.Cut(server, "#")
+	addr, err = serverAddrPort(hostPort, "853")
+	if err != nil {
+		return "", "", err
+	}
+	if name == "" {
+		name = defaultName
+	}
+	if name == "" {
+		name, _, _ = net.SplitHostPort(addr)
+	}
+	return addr, name, nil
+}
+
+// newDoTResolver resolves over DNS-over-TLS (RFC 7858), verifying the server's
+// certificate against serverName
+func newDoTResolver(server, addr, serverName string) *wireResolver {
+	client := &dns.Client{
+		Net:       "tcp-tls",
+		TLSConfig: &tls.Config{ServerName: serverName},
+	}
+	return &wireResolver{
+		server: server,
+		exchange: func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+			resp, _, err := client.ExchangeContext(ctx, msg, addr)
+			if err != nil {
+				return nil, fmt.Errorf("DoT exchange with %s: %w", addr, err)
+			}
+			return resp, nil
+		},
+	}
+}
+
+// certError reports whether err comes from a TLS certificate that failed
+// verification, e.g. an expired certificate or a server name mismatch
+func certError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	return errors.As(err, &verifyErr)
+}
@@ -0,0 +1,203 @@
+package dnsmonitor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// dmarcPolicyRank orders DMARC policies from weakest to strongest
+var dmarcPolicyRank = map[string]int{"none": 0, "quarantine": 1, "reject": 2}
+
+// validateCheckPolicy checks a check's validate settings at config load
+func validateCheckPolicy(check *DNSCheck) error {
+	if check.Validate == "" {
+		if check.MinPolicy != "" {
+			return fmt.Errorf("min_policy requires validate: dmarc")
+		}
+		return nil
+	}
+	switch check.Validate {
+	case "spf", "dkim":
+		if check.MinPolicy != "" {
+			return fmt.Errorf("min_policy requires validate: dmarc")
+		}
+	case "dmarc":
+		if _, ok := dmarcPolicyRank[check.MinPolicy]; check.MinPolicy != "" && !ok {
+			return fmt.Errorf("invalid min_policy %q, must be none, quarantine or reject", check.MinPolicy)
+		}
+	default:
+		return fmt.Errorf("invalid validate mode %q, must be spf, dmarc or dkim", check.Validate)
+	}
+	if check.Type != "TXT" {
+		return fmt.Errorf("validate: %s requires type TXT", check.Validate)
+	}
+	return nil
+}
+
+// validateEmailAuth parses a check's TXT records according to its validate mode
+func validateEmailAuth(check *DNSCheck, records []string) error {
+	switch check.Validate {
+	case "spf":
+		return validateSPF(records)
+	case "dmarc":
+		return validateDMARC(records, check.MinPolicy)
+	case "dkim":
+		return validateDKIM(records)
+	}
+	return fmt.Errorf("unknown validate mode %q", check.Validate)
+}
+
+// findTagRecord returns the single record starting with the given version tag
+func findTagRecord(records []string, version string) (string, error) {
+	var found []string
+	for _, record := range records {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(record)), strings.ToLower(version)) {
+			found = append(found, strings.TrimSpace(record))
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("no %s record found", version)
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("multiple %s records found", version)
+}
+
+func validateSPF(records []string) error {
+	record, err := findTagRecord(records, "v=spf1")
+	if err != nil {
+		return err
+	}
+
+	terms := strings.Fields(record)[1:]
+	for i, term := range terms {
+		lower := strings.ToLower(term)
+
+		// Modifiers
+		if strings.HasPrefix(lower, "redirect=") || strings.HasPrefix(lower, "exp=") {
+			if strings.TrimPrefix(strings.TrimPrefix(lower, "redirect="), "exp=") == "" {
+				return fmt.Errorf("SPF modifier %q has no value", term)
+			}
+			continue
+		}
+
+		qualifier := "+"
+		if strings.ContainsAny(lower[:1], "+-~?") {
+			qualifier, lower = lower[:1], lower[1:]
+		}
+
+		name, value, _ := strings.Cut(lower, ":")
+		name, _, _ = strings.Cut(name, "/")
+		switch name {
+		case "all":
+			if qualifier == "+" {
+				return fmt.Errorf("SPF record allows any sender with %q", term)
+			}
+			if i != len(terms)-1 {
+				return fmt.Errorf("SPF terms after %q are never evaluated", term)
+			}
+		case "include", "exists":
+			if value == "" {
+				return fmt.Errorf("SPF mechanism %q requires a domain", term)
+			}
+		case "ip4", "ip6":
+			if value == "" {
+				return fmt.Errorf("SPF mechanism %q requires an address", term)
+			}
+			if !strings.Contains(value, "/") {
+				if net.ParseIP(value) == nil {
+					return fmt.Errorf("SPF mechanism %q has an invalid address", term)
+				}
+			} else if _, _, err := net.ParseCIDR(value); err != nil {
+				return fmt.Errorf("SPF mechanism %q has an invalid network", term)
+			}
+		case "a", "mx", "ptr":
+		default:
+			return fmt.Errorf("unknown SPF term %q", term)
+		}
+	}
+	return nil
+}
+
+// parseTags splits a "k=v; k=v" record as used by DMARC and DKIM
+func parseTags(record string) map[string]string {
+	tags := make(map[string]string)
+	for _, part := range strings.Split(record, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if ok {
+			tags[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	return tags
+}
+
+func validateDMARC(records []string, minPolicy string) error {
+	record, err := findTagRecord(records, "v=DMARC1")
+	if err != nil {
+		return err
+	}
+	tags := parseTags(record)
+
+	policy, ok := tags["p"]
+	if !ok {
+		return fmt.Errorf("DMARC record has no p= policy")
+	}
+	rank, ok := dmarcPolicyRank[strings.ToLower(policy)]
+	if !ok {
+		return fmt.Errorf("DMARC record has invalid policy p=%s", policy)
+	}
+	if minPolicy == "" {
+		minPolicy = "quarantine"
+	}
+	if rank < dmarcPolicyRank[minPolicy] {
+		return fmt.Errorf("DMARC policy p=%s is weaker than %s", policy, minPolicy)
+	}
+
+	if sp, ok := tags["sp"]; ok {
+		if spRank, ok := dmarcPolicyRank[strings.ToLower(sp)]; !ok || spRank < dmarcPolicyRank[minPolicy] {
+			return fmt.Errorf("DMARC subdomain policy sp=%s is weaker than %s", sp, minPolicy)
+		}
+	}
+	if pct, ok := tags["pct"]; ok {
+		if n, err := strconv.Atoi(pct); err != nil || n < 0 || n > 100 {
+			return fmt.Errorf("DMARC record has invalid pct=%s", pct)
+		} else if n < 100 {
+			return fmt.Errorf("DMARC policy only applies to pct=%d%% of mail", n)
+		}
+	}
+	return nil
+}
+
+func validateDKIM(records []string) error {
+	var record string
+	for _, r := range records {
+		if tags := parseTags(r); tags["p"] != "" || strings.HasPrefix(strings.ToLower(strings.TrimSpace(r)), "v=dkim1") {
+			record = r
+			break
+		}
+	}
+	if record == "" {
+		return fmt.Errorf("no DKIM record found")
+	}
+	tags := parseTags(record)
+
+	if v, ok := tags["v"]; ok && !strings.EqualFold(v, "DKIM1") {
+		return fmt.Errorf("DKIM record has invalid version v=%s", v)
+	}
+	if k, ok := tags["k"]; ok && k != "rsa" && k != "ed25519" {
+		return fmt.Errorf("DKIM record has unsupported key type k=%s", k)
+	}
+
+	key := strings.Join(strings.Fields(tags["p"]), "")
+	if key == "" {
+		return fmt.Errorf("DKIM key has been revoked (empty p=)")
+	}
+	if _, err := base64.StdEncoding.DecodeString(key); err != nil {
+		return fmt.Errorf("DKIM public key is not valid base64")
+	}
+	return nil
+}
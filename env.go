@@ -0,0 +1,66 @@
+package dnsmonitor
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envReference matches ${NAME}, ${NAME:-default} and the $${ escape
+var envReference = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv substitutes environment variables in the values of a parsed config
+// file. ${NAME} must be set; ${NAME:-default} falls back to the default when
+// NAME is unset or empty, and $${ is a literal ${. Keys and comments are left
+// alone, and a value stays a single value whatever the variable holds.
+func expandEnv(doc *yaml.Node) error {
+	var missing []string
+	expandEnvValues(doc, &missing)
+	if len(missing) > 0 {
+		return fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func expandEnvValues(node *yaml.Node, missing *[]string) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		expanded := envReference.ReplaceAllStringFunc(node.Value, func(match string) string {
+			if match == "$${" {
+				return "${"
+			}
+			groups := envReference.FindStringSubmatch(match)
+			name := groups[1]
+			if value := os.Getenv(name); value != "" {
+				return value
+			}
+			if groups[2] != "" {
+				return groups[3]
+			}
+			if _, ok := os.LookupEnv(name); !ok && !slices.Contains(*missing, name) {
+				*missing = append(*missing, name)
+			}
+			return ""
+		})
+		if expanded != node.Value {
+			node.Value = expanded
+			// Unquoted values are typed by what they expand to, as if written out
+			if node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+				node.Tag = ""
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			expandEnvValues(node.Content[i], missing)
+		}
+	case yaml.DocumentNode, yaml.SequenceNode:
+		// Aliases are skipped; the node they refer to is expanded where it is defined
+		for _, child := range node.Content {
+			expandEnvValues(child, missing)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package dnsmonitor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// validExecDomain matches the domains EXEC checks may pass to their command:
+// plain host names, which cannot be mistaken for an option or smuggle in
+// anything else
+var validExecDomain = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// runCommand runs an EXEC check's command, without a shell, with the domain as
+// its last argument and the server being polled in DNS_MONITOR_SERVER. The
+// non-empty lines it prints become the check's records; a non-zero exit status
+// is an error carrying the first line of its stderr.
+func runCommand(ctx context.Context, check *DNSCheck, server string) ([]string, error) {
+	args := append(append([]string(nil), check.Command[1:]...), check.queryName())
+	cmd := exec.CommandContext(ctx, check.Command[0], args...)
+	cmd.Env = append(os.Environ(), "DNS_MONITOR_SERVER="+server)
+	// Don't wait on children that keep the output pipes open after a timeout
+	cmd.WaitDelay = time.Second
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		detail, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+		return nil, fmt.Errorf("%s exited with status %d: %s", check.Command[0], exitErr.ExitCode(), detail)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error running %s: %v", check.Command[0], err)
+	}
+
+	var records []string
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			records = append(records, line)
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,273 @@
+package dnsmonitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Snapshot returns a copy of the check's history, oldest first, that stays
+// consistent while polls keep appending to the check
+func (check *DNSCheck) Snapshot() []CheckResult {
+	check.historyLock.RLock()
+	defer check.historyLock.RUnlock()
+	return slices.Clone(check.History)
+}
+
+// AnnotationsSnapshot returns a copy of the check's annotations, oldest first
+func (check *DNSCheck) AnnotationsSnapshot() []Annotation {
+	check.historyLock.RLock()
+	defer check.historyLock.RUnlock()
+	return sortedAnnotations(check.Annotations)
+}
+
+// sortedAnnotations copies annotations in time order, since notes posted with
+// a timestamp may be older than ones already recorded
+func sortedAnnotations(annotations []Annotation) []Annotation {
+	sorted := slices.Clone(annotations)
+	slices.SortStableFunc(sorted, func(a, b Annotation) int { return a.Timestamp.Compare(b.Timestamp) })
+	return sorted
+}
+
+// attachAnnotations assigns each annotation to the last of times, which are
+// roughly oldest first, at or before it; ones older than all of them go to the
+// first
+func attachAnnotations(times []time.Time, annotations []Annotation) [][]Annotation {
+	if len(times) == 0 {
+		return nil
+	}
+	attached := make([][]Annotation, len(times))
+	for _, annotation := range annotations {
+		i := len(times) - 1
+		for i > 0 && times[i].After(annotation.Timestamp) {
+			i--
+		}
+		attached[i] = append(attached[i], annotation)
+	}
+	return attached
+}
+
+// HasResults reports whether the check has recorded any result yet
+func (check *DNSCheck) HasResults() bool {
+	check.historyLock.RLock()
+	defer check.historyLock.RUnlock()
+	return len(check.History) > 0
+}
+
+// Latest returns a copy of the check's most recent result, or nil before the
+// first poll
+func (check *DNSCheck) Latest() *CheckResult {
+	check.historyLock.RLock()
+	defer check.historyLock.RUnlock()
+	if latest := lastCheck(check.History); latest != nil {
+		result := *latest
+		return &result
+	}
+	return nil
+}
+
+// Diff describes how the latest result differs from the previous one from the
+// same server, or returns nil when nothing changed
+func (check *DNSCheck) Diff() *ResultDiff {
+	check.historyLock.RLock()
+	defer check.historyLock.RUnlock()
+	return resultDiff(check.History)
+}
+
+// appendResult adds a result to the check's history. With dedupe_history, a
+// result with the same outcome as the latest one from its server only extends
+// that entry, so a stable check keeps a single entry per server.
+// Callers must hold check.historyLock.
+func (check *DNSCheck) appendResult(result CheckResult) {
+	if check.dedupeHistory {
+		for i := len(check.History) - 1; i >= 0; i-- {
+			previous := &check.History[i]
+			if previous.Server != result.Server {
+				continue
+			}
+			if sameOutcome(*previous, result) {
+				seen := result.Timestamp
+				previous.LastSeen = &seen
+				previous.Count = previous.polls() + 1
+				return
+			}
+			break
+		}
+	}
+	check.History = append(check.History, result)
+}
+
+// trimHistory drops the oldest entries beyond the check's max_history_entries.
+// Callers must hold check.historyLock.
+func (check *DNSCheck) trimHistory() {
+	if limit := check.MaxHistoryEntries; limit > 0 && len(check.History) > limit {
+		check.History = slices.Clone(check.History[len(check.History)-limit:])
+	}
+}
+
+// sameOutcome reports whether two results have the same status and records
+func sameOutcome(a, b CheckResult) bool {
+	return a.Status == b.Status && a.Error == b.Error && a.Detail == b.Detail &&
+		sameRecordSet(a.ActualResult, b.ActualResult)
+}
+
+// polls is how many polls a history entry stands for
+func (r CheckResult) polls() int {
+	return max(r.Count, 1)
+}
+
+// seen is when the entry's outcome was last observed
+func (r CheckResult) seen() time.Time {
+	if r.LastSeen != nil {
+		return *r.LastSeen
+	}
+	return r.Timestamp
+}
+
+// pollsSince is how many of the entry's polls ran at or after the given time.
+// The polls of a deduplicated entry are taken to be evenly spread between its
+// Timestamp and LastSeen.
+func (r CheckResult) pollsSince(since time.Time) int {
+	switch {
+	case !r.Timestamp.Before(since):
+		return r.polls()
+	case r.seen().Before(since):
+		return 0
+	}
+	span := r.seen().Sub(r.Timestamp)
+	gaps := r.polls() - 1
+	skipped := int(math.Ceil(float64(since.Sub(r.Timestamp)) * float64(gaps) / float64(span)))
+	return r.polls() - skipped
+}
+
+// timelineLength is how many polls the status page timeline shows per check, so
+// checks with thousands of results still render quickly
+const timelineLength = 120
+
+// TimelinePoll summarises one poll of a check across all servers
+type TimelinePoll struct {
+	Timestamp   time.Time
+	Class       string
+	Statuses    []string
+	Annotations []Annotation
+}
+
+// Timeline groups a check's most recent results into polls, oldest first. A
+// poll has at most one result per server, followed by any result comparing
+// the servers, and is timestamped with its earliest result. It takes the class
+// of its MISMATCH result if any, otherwise of its first result that did not pass.
+// Annotations are attached to the poll they were made during or after; those
+// older than the timeline are left out.
+func (c *Config) Timeline(check *DNSCheck) []TimelinePoll {
+	check.historyLock.RLock()
+	defer check.historyLock.RUnlock()
+
+	var polls []TimelinePoll
+	var servers map[string]bool
+	for i := len(check.History) - 1; i >= 0; i-- {
+		result := check.History[i]
+		// Walking backwards, a server seen twice or a comparison after any
+		// server's result belongs to the previous poll
+		comparison := !slices.Contains(c.servers, result.Server)
+		if len(polls) == 0 || servers[result.Server] || (comparison && len(servers) > 0) {
+			if len(polls) == timelineLength {
+				break
+			}
+			polls = append(polls, TimelinePoll{Class: "PASS"})
+			servers = make(map[string]bool)
+		}
+		servers[result.Server] = true
+
+		poll := &polls[len(polls)-1]
+		if poll.Timestamp.IsZero() || result.Timestamp.Before(poll.Timestamp) {
+			poll.Timestamp = result.Timestamp
+		}
+		// Walking backwards, so prepend to keep server order
+		poll.Statuses = append([]string{result.Describe()}, poll.Statuses...)
+		switch class := statusClass(result.Status); {
+		case class == "MISMATCH":
+			poll.Class = class
+		case class != "PASS" && poll.Class != "MISMATCH":
+			poll.Class = class
+		}
+	}
+
+	slices.Reverse(polls)
+
+	if len(polls) > 0 {
+		times := make([]time.Time, len(polls))
+		for i, poll := range polls {
+			times[i] = poll.Timestamp
+		}
+		var shown []Annotation
+		for _, annotation := range sortedAnnotations(check.Annotations) {
+			if !annotation.Timestamp.Before(times[0]) {
+				shown = append(shown, annotation)
+			}
+		}
+		for i, annotations := range attachAnnotations(times, shown) {
+			polls[i].Annotations = annotations
+		}
+	}
+	return polls
+}
+
+// historyEntry is a result as served by the history API, with the annotations
+// made between it and the next result
+type historyEntry struct {
+	CheckResult
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+// historyHandler serves GET /api/history/{id} and, for checks that do not share
+// their domain and type, GET /api/history/{domain}/{type}: the check's retained
+// results as JSON, oldest first, optionally limited to those at or after ?since=.
+// Each result carries the annotations made between it and the next one.
+func historyHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var since time.Time
+		if s := r.URL.Query().Get("since"); s != "" {
+			var err error
+			if since, err = time.Parse(time.RFC3339, s); err != nil {
+				http.Error(w, fmt.Sprintf("invalid since: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		config.mu.RLock()
+		check, lookupErr := config.lookupCheck(r)
+		config.mu.RUnlock()
+		if lookupErr != nil {
+			http.Error(w, lookupErr.Error(), lookupErr.status)
+			return
+		}
+
+		history := make([]historyEntry, 0)
+		var times []time.Time
+		for _, result := range check.Snapshot() {
+			// A deduplicated entry is included while it is still being seen
+			if !result.seen().Before(since) {
+				history = append(history, historyEntry{CheckResult: result})
+				times = append(times, result.Timestamp)
+			}
+		}
+		var annotations []Annotation
+		for _, annotation := range check.AnnotationsSnapshot() {
+			if !annotation.Timestamp.Before(since) {
+				annotations = append(annotations, annotation)
+			}
+		}
+		for i, attached := range attachAnnotations(times, annotations) {
+			history[i].Annotations = attached
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(history); err != nil {
+			log.Printf("Error encoding history response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package dnsmonitor
+
+import (
+	"golang.org/x/net/idna"
+)
+
+// idnProfile converts internationalized domain names to the ASCII form sent
+// on the wire. Unlike idna.Lookup it allows underscores, which service labels
+// such as _dmarc and _domainkey need.
+var idnProfile = idna.New(
+	idna.MapForLookup(),
+	idna.StrictDomainName(false),
+	idna.BidiRule(),
+	idna.Transitional(false),
+)
+
+// asciiDomain returns the punycode form of a domain with non-ASCII labels.
+// ASCII domains are returned unchanged.
+func asciiDomain(domain string) (string, error) {
+	for i := 0; i < len(domain); i++ {
+		if domain[i] >= 0x80 {
+			return idnProfile.ToASCII(domain)
+		}
+	}
+	return domain, nil
+}
+
+// queryName is the check's domain as sent to DNS servers: the punycode form
+// for internationalized domains, which are otherwise shown as configured
+func (check *DNSCheck) queryName() string {
+	if check.asciiDomain != "" {
+		return check.asciiDomain
+	}
+	return check.Domain
+}
@@ -0,0 +1,106 @@
+package dnsmonitor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadIncludes appends the checks of every file matched by the config's include
+// entries. An entry is a glob pattern or a directory, whose *.yaml and *.yml
+// files are all read; relative entries are resolved against the main config
+// file's directory. Files are read in name order, each at most once, and may
+// only define checks: global settings come from the main file alone.
+func (c *Config) loadIncludes(filename string) error {
+	dir := filepath.Dir(filename)
+	read := map[string]bool{filepath.Clean(filename): true}
+	for _, entry := range c.Include {
+		pattern := entry
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		patterns := []string{pattern}
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			patterns = []string{filepath.Join(pattern, "*.yaml"), filepath.Join(pattern, "*.yml")}
+		}
+
+		var files []string
+		for _, pattern := range patterns {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid include %q: %v", entry, err)
+			}
+			files = append(files, matches...)
+		}
+		if len(files) == 0 {
+			log.Printf("Warning: include %q matches no files", entry)
+		}
+
+		for _, file := range files {
+			if read[filepath.Clean(file)] {
+				continue
+			}
+			read[filepath.Clean(file)] = true
+			checks, err := loadIncludedChecks(file, c.templates)
+			if err != nil {
+				return err
+			}
+			c.Checks = append(c.Checks, checks...)
+		}
+	}
+	return nil
+}
+
+// includedFile is the layout of an included file
+type includedFile struct {
+	Checks []*DNSCheck `yaml:"checks"`
+}
+
+// loadIncludedChecks reads the checks defined in an included file, which may
+// use the main file's templates
+func loadIncludedChecks(filename string, templates map[string]*yaml.Node) ([]*DNSCheck, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading included file: %v", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", filename, err)
+	}
+	if err := expandEnv(&doc); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", filename, err)
+	}
+	// The strict decoder below reads text, so write the expanded values back out
+	if data, err = yaml.Marshal(&doc); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", filename, err)
+	}
+
+	var included includedFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&included); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing %s (included files may only define checks): %v", filename, err)
+	}
+
+	// Fields are checked above; decode again with the templates merged in
+	if slices.ContainsFunc(included.Checks, func(check *DNSCheck) bool { return check.Template != "" }) {
+		if err := applyTemplates(&doc, templates); err != nil {
+			return nil, fmt.Errorf("error applying templates in %s: %v", filename, err)
+		}
+		included = includedFile{}
+		if err := doc.Decode(&included); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", filename, err)
+		}
+	}
+	for _, check := range included.Checks {
+		check.source = filename
+	}
+	return included.Checks, nil
+}
@@ -0,0 +1,181 @@
+package dnsmonitor
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// labelFilter selects checks by label. Each entry is "key:value", or just "key"
+// to select every check that has the label; a check must match all entries.
+type labelFilter []string
+
+// labelFilterFromRequest reads the repeatable ?label= query parameter
+func labelFilterFromRequest(r *http.Request) labelFilter {
+	var filter labelFilter
+	for _, label := range r.URL.Query()["label"] {
+		if label = strings.TrimSpace(label); label != "" {
+			filter = append(filter, label)
+		}
+	}
+	return filter
+}
+
+// matches reports whether a check carries every label in the filter
+func (f labelFilter) matches(check *DNSCheck) bool {
+	for _, label := range f {
+		key, value, hasValue := strings.Cut(label, ":")
+		actual, ok := check.Labels[key]
+		if !ok || (hasValue && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Labels are free-form key/value tags on a check, such as team or environment
+type Labels map[string]string
+
+// Sorted returns the labels as "key:value" strings in key order
+func (l Labels) Sorted() []string {
+	labels := make([]string, 0, len(l))
+	for key, value := range l {
+		labels = append(labels, key+":"+value)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// CheckGroup is a set of checks shown under one heading on the status page.
+// With ?view=domain its checks are also collected into Domains.
+type CheckGroup struct {
+	Name    string
+	Checks  []*DNSCheck
+	Domains []DomainCard
+}
+
+// DomainCard is the checks of one domain, shown as a single card with a row
+// per check
+type DomainCard struct {
+	Domain string
+	Checks []*DNSCheck
+}
+
+// Status is the status class of the card's most severe check
+func (d DomainCard) Status() string {
+	return summarize(d.Checks).Worst
+}
+
+// domainCards collects checks by domain, ignoring case and a trailing dot.
+// Cards are in the order their domain first appears, as are their checks.
+func domainCards(checks []*DNSCheck) []DomainCard {
+	index := make(map[string]int)
+	var cards []DomainCard
+	for _, check := range checks {
+		key := strings.ToLower(strings.TrimSuffix(check.Domain, "."))
+		i, ok := index[key]
+		if !ok {
+			i = len(cards)
+			index[key] = i
+			cards = append(cards, DomainCard{Domain: check.Domain})
+		}
+		cards[i].Checks = append(cards[i].Checks, check)
+	}
+	return cards
+}
+
+// statusPage is what the status page template renders: the config, with its
+// checks narrowed by the request's label filter, optionally grouped by the
+// value of one label and shown one card per domain. Fields and methods of
+// Config remain available.
+type statusPage struct {
+	*Config
+	Checks   []*DNSCheck
+	Groups   []CheckGroup
+	Filter   labelFilter
+	GroupBy  string
+	ByDomain bool
+
+	query url.Values
+}
+
+// newStatusPage builds the page for a request. Callers must hold config.mu.
+func (c *Config) newStatusPage(r *http.Request) *statusPage {
+	query := r.URL.Query()
+	page := &statusPage{
+		Config:   c,
+		Filter:   labelFilterFromRequest(r),
+		GroupBy:  query.Get("group"),
+		ByDomain: query.Get("view") == "domain",
+		query:    query,
+	}
+	for _, check := range c.Checks {
+		if page.Filter.matches(check) {
+			page.Checks = append(page.Checks, check)
+		}
+	}
+
+	page.groupChecks()
+	if page.ByDomain {
+		for i := range page.Groups {
+			page.Groups[i].Domains = domainCards(page.Groups[i].Checks)
+		}
+	}
+	return page
+}
+
+// groupChecks splits the page's checks into groups by the ?group= label
+func (p *statusPage) groupChecks() {
+	if p.GroupBy == "" {
+		p.Groups = []CheckGroup{{Checks: p.Checks}}
+		return
+	}
+
+	// Groups are ordered by label value; checks without the label come last
+	index := make(map[string]int)
+	var unlabelled []*DNSCheck
+	for _, check := range p.Checks {
+		value, ok := check.Labels[p.GroupBy]
+		if !ok {
+			unlabelled = append(unlabelled, check)
+			continue
+		}
+		i, ok := index[value]
+		if !ok {
+			i = len(p.Groups)
+			index[value] = i
+			p.Groups = append(p.Groups, CheckGroup{Name: p.GroupBy + ":" + value})
+		}
+		p.Groups[i].Checks = append(p.Groups[i].Checks, check)
+	}
+	sort.SliceStable(p.Groups, func(i, j int) bool { return p.Groups[i].Name < p.Groups[j].Name })
+	if len(unlabelled) > 0 {
+		p.Groups = append(p.Groups, CheckGroup{Name: "no " + p.GroupBy + " label", Checks: unlabelled})
+	}
+}
+
+// ViewLink returns the page's URL, keeping its filter and grouping, showing
+// one card per domain or one box per check
+func (p *statusPage) ViewLink(byDomain bool) string {
+	query := url.Values{}
+	for key, values := range p.query {
+		query[key] = values
+	}
+	query.Del("view")
+	if byDomain {
+		query.Set("view", "domain")
+	}
+	if len(query) == 0 {
+		return "/"
+	}
+	return "/?" + query.Encode()
+}
+
+// Due reports whether a check's next poll time has passed, meaning it is
+// being polled or waiting for a free worker
+func (p *statusPage) Due(check *DNSCheck) bool {
+	next := p.NextRun(check)
+	return !next.IsZero() && !next.After(time.Now())
+}
@@ -0,0 +1,239 @@
+package dnsmonitor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type LeaderElectionConfig struct {
+	LockFile      string        `yaml:"lock_file"`
+	LeaseDuration time.Duration `yaml:"lease_duration"`
+	InstanceID    string        `yaml:"instance_id"`
+}
+
+// leaderLock is a lease that at most one replica holds at a time.
+// TryAcquire both acquires a free lease and renews one already held.
+type leaderLock interface {
+	TryAcquire() (bool, error)
+	Release() error
+}
+
+// fileLock implements leaderLock with a lease file on storage shared by all
+// replicas. The file holds the owner's instance ID and the lease expiry.
+// A free lease is created with O_EXCL, so only one replica can create it, and
+// an expired one is first moved aside, which only one replica can do to the
+// lease it read. Only the holder of a lease that has not expired renews it.
+type fileLock struct {
+	path  string
+	id    string
+	lease time.Duration
+}
+
+func (l *fileLock) TryAcquire() (bool, error) {
+	data, err := os.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return l.create()
+	}
+	if err != nil {
+		return false, fmt.Errorf("error reading lock file: %v", err)
+	}
+
+	holder, expiry, ok := parseLease(data)
+	if !ok {
+		// Being written by the replica that just created it, unless it was
+		// left behind half written
+		info, err := os.Stat(l.path)
+		if err != nil || time.Since(info.ModTime()) < l.lease {
+			return false, nil
+		}
+	} else if time.Now().Before(expiry) {
+		if holder != l.id {
+			return false, nil
+		}
+		return l.renew()
+	}
+
+	// The lease expired: whoever moves it aside first may create a new one
+	taken, err := l.take(data)
+	if err != nil || !taken {
+		return false, err
+	}
+	return l.create()
+}
+
+// parseLease splits a lease file into its holder and expiry
+func parseLease(data []byte) (holder string, expiry time.Time, ok bool) {
+	parts := strings.SplitN(strings.TrimSpace(string(data)), "\t", 2)
+	if len(parts) != 2 {
+		return "", time.Time{}, false
+	}
+	expiry, err := time.Parse(time.RFC3339Nano, parts[1])
+	return parts[0], expiry, err == nil
+}
+
+func (l *fileLock) leaseLine() []byte {
+	return []byte(fmt.Sprintf("%s\t%s\n", l.id, time.Now().Add(l.lease).Format(time.RFC3339Nano)))
+}
+
+// sidePath is a file name next to the lock that only this replica uses
+func (l *fileLock) sidePath(suffix string) string {
+	return fmt.Sprintf("%s.%x.%s", l.path, l.id, suffix)
+}
+
+// create takes a free lease, failing if another replica created it first
+func (l *fileLock) create() (bool, error) {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error creating lock file: %v", err)
+	}
+	_, err = f.Write(l.leaseLine())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, fmt.Errorf("error writing lock file: %v", err)
+	}
+	return true, nil
+}
+
+// renew extends a lease this replica holds. The new lease is written to a
+// temp file and renamed over the old one so readers never see a partial lease.
+func (l *fileLock) renew() (bool, error) {
+	tmp := l.sidePath("tmp")
+	if err := os.WriteFile(tmp, l.leaseLine(), 0644); err != nil {
+		return false, fmt.Errorf("error writing lock file: %v", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return false, fmt.Errorf("error writing lock file: %v", err)
+	}
+	return true, nil
+}
+
+// take moves the lease file aside if it still holds lease, as read earlier.
+// Renames are atomic, so of the replicas that read the same lease only one
+// moves it; one that moved a newer lease instead puts it back.
+func (l *fileLock) take(lease []byte) (bool, error) {
+	aside := l.sidePath("old")
+	if err := os.Rename(l.path, aside); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error replacing lock file: %v", err)
+	}
+	moved, err := os.ReadFile(aside)
+	if err == nil && bytes.Equal(moved, lease) {
+		return true, os.Remove(aside)
+	}
+
+	// Link fails rather than overwrite a lease created in the meantime
+	if err := os.Link(aside, l.path); err != nil && !os.IsExist(err) {
+		log.Printf("Error restoring lock file: %v", err)
+	}
+	os.Remove(aside)
+	return false, nil
+}
+
+func (l *fileLock) Release() error {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return nil
+	}
+	if holder, _, ok := parseLease(data); !ok || holder != l.id {
+		return nil
+	}
+	_, err = l.take(data)
+	return err
+}
+
+type leaderElector struct {
+	lock     leaderLock
+	interval time.Duration
+	leader   atomic.Bool
+
+	// stop ends run, which closes done once it has returned
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+func newLeaderElector(cfg LeaderElectionConfig) *leaderElector {
+	lease := cfg.LeaseDuration
+	if lease == 0 {
+		lease = 30 * time.Second
+	}
+
+	id := cfg.InstanceID
+	if id == "" {
+		hostname, _ := os.Hostname()
+		id = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	return &leaderElector{
+		lock:     &fileLock{path: cfg.LockFile, id: id, lease: lease},
+		interval: lease / 3,
+	}
+}
+
+// campaign tries to acquire or renew the lease once, logging role changes
+func (e *leaderElector) campaign() {
+	acquired, err := e.lock.TryAcquire()
+	if err != nil {
+		log.Printf("Error during leader election: %v", err)
+		acquired = false
+	}
+
+	if was := e.leader.Swap(acquired); was != acquired {
+		if acquired {
+			log.Printf("Acquired leadership, starting active polling")
+		} else {
+			log.Printf("Running as follower, serving results from shared logs")
+		}
+	}
+}
+
+// start renews or campaigns for the lease in the background until resign
+func (e *leaderElector) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.stop, e.done = cancel, make(chan struct{})
+	go e.run(ctx)
+}
+
+func (e *leaderElector) run(ctx context.Context) {
+	defer close(e.done)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.campaign()
+		}
+	}
+}
+
+// resign stops campaigning and releases the lease if this instance holds it,
+// so another replica takes over without waiting for the lease to expire
+func (e *leaderElector) resign() {
+	if e.stop != nil {
+		e.stop()
+		<-e.done
+	}
+	if !e.leader.Swap(false) {
+		return
+	}
+	if err := e.lock.Release(); err != nil {
+		log.Printf("Error releasing leadership: %v", err)
+		return
+	}
+	log.Printf("Released leadership")
+}
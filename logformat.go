@@ -0,0 +1,235 @@
+package dnsmonitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonLogEntry is one line of a JSON history log
+type jsonLogEntry struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Domain    string        `json:"domain"`
+	Type      string        `json:"type"`
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	Detail    string        `json:"detail,omitempty"`
+	Server    string        `json:"server"`
+	Results   []string      `json:"results"`
+	Duration  time.Duration `json:"duration"`
+	Attempts  int           `json:"attempts,omitempty"`
+}
+
+// formatLogEntry renders a result as a single history log line in the given format
+func formatLogEntry(check *DNSCheck, result CheckResult, format string) string {
+	switch format {
+	case "json":
+		line, _ := json.Marshal(jsonLogEntry{
+			Timestamp: result.Timestamp,
+			Domain:    check.Domain,
+			Type:      check.Type,
+			Status:    result.Status,
+			Error:     result.Error,
+			Detail:    result.Detail,
+			Server:    result.Server,
+			Results:   result.ActualResult,
+			Duration:  result.Duration,
+			Attempts:  result.Attempts,
+		})
+		return string(line) + "\n"
+	case "logfmt":
+		line := fmt.Sprintf("ts=%s status=%s server=%s duration=%s attempts=%d results=%s",
+			result.Timestamp.Format(time.RFC3339),
+			logfmtValue(result.Status),
+			logfmtValue(result.Server),
+			result.Duration,
+			result.Attempts,
+			logfmtValue(strings.Join(result.ActualResult, ",")))
+		if result.Error != "" {
+			line += " error=" + logfmtValue(result.Error)
+		}
+		if result.Detail != "" {
+			line += " detail=" + logfmtValue(result.Detail)
+		}
+		return line + "\n"
+	}
+
+	// Attempts, error and detail are trailing columns so older logs still parse
+	return fmt.Sprintf("%s\t%s\t%s\t%v\t%d\t%s\t%s\n",
+		result.Timestamp.Format(time.RFC3339),
+		result.Status,
+		result.Server,
+		strings.Join(result.ActualResult, ","),
+		result.Attempts,
+		tsvValue(result.Error),
+		tsvValue(result.Detail))
+}
+
+// tsvValue flattens tabs and line breaks so a value stays in its column
+func tsvValue(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// parseLogLine parses a history log line in any supported format. Lines that are
+// not history entries return ok == false; a bad timestamp returns an error.
+func parseLogLine(line string) (result CheckResult, ok bool, err error) {
+	result, ok, err = parseLogEntry(line)
+	if ok {
+		migrateStatus(&result)
+	}
+	return result, ok, err
+}
+
+// migrateStatus converts a status written before statuses became plain kinds,
+// "domain-type-KIND[-text]", into the kind and its error or detail text
+func migrateStatus(result *CheckResult) {
+	status := result.Status
+	if slices.Contains(statusKinds, status) || status == "UNSUPPORTED" {
+		return
+	}
+
+	// The kind is the earliest "-KIND" followed by a dash or the end; the domain
+	// comes before it and the text after it may contain anything
+	at, kind := -1, ""
+	for _, k := range append([]string{"UNSUPPORTED"}, statusKinds...) {
+		for from := 0; ; {
+			i := strings.Index(status[from:], "-"+k)
+			if i < 0 {
+				break
+			}
+			i += from
+			if end := i + 1 + len(k); end == len(status) || status[end] == '-' {
+				if at < 0 || i < at {
+					at, kind = i, k
+				}
+				break
+			}
+			from = i + 1
+		}
+	}
+	if at < 0 {
+		return
+	}
+
+	text := strings.TrimPrefix(status[at+1+len(kind):], "-")
+	result.Status = kind
+	switch kind {
+	case "ERROR", "TIMEOUT", "TRANSIENT", "CERT":
+		result.Error = text
+	default:
+		result.Detail = text
+	}
+}
+
+func parseLogEntry(line string) (CheckResult, bool, error) {
+	if strings.HasPrefix(line, "ts=") {
+		return parseLogfmtLine(line)
+	}
+	if strings.HasPrefix(line, "{") {
+		return parseJSONLine(line)
+	}
+
+	parts := strings.Split(line, "\t")
+	if len(parts) < 4 {
+		return CheckResult{}, false, nil
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return CheckResult{}, false, err
+	}
+
+	result := CheckResult{
+		Status:       parts[1],
+		Server:       parts[2],
+		Timestamp:    timestamp,
+		ActualResult: strings.Split(parts[3], ","),
+	}
+	if len(parts) > 4 {
+		result.Attempts, _ = strconv.Atoi(parts[4])
+	}
+	if len(parts) > 6 {
+		result.Error, result.Detail = parts[5], parts[6]
+	}
+	return result, true, nil
+}
+
+func parseLogfmtLine(line string) (CheckResult, bool, error) {
+	fields := make(map[string]string)
+	rest := strings.TrimSpace(line)
+	for rest != "" {
+		key, value, found := strings.Cut(rest, "=")
+		if !found || key == "" || strings.Contains(key, " ") {
+			return CheckResult{}, false, nil
+		}
+
+		if strings.HasPrefix(value, `"`) {
+			quoted, err := strconv.QuotedPrefix(value)
+			if err != nil {
+				return CheckResult{}, false, nil
+			}
+			rest = strings.TrimSpace(value[len(quoted):])
+			value, _ = strconv.Unquote(quoted)
+		} else {
+			value, rest, _ = strings.Cut(value, " ")
+			rest = strings.TrimSpace(rest)
+		}
+		fields[key] = value
+	}
+
+	if _, ok := fields["status"]; !ok {
+		return CheckResult{}, false, nil
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, fields["ts"])
+	if err != nil {
+		return CheckResult{}, false, err
+	}
+
+	result := CheckResult{
+		Status:    fields["status"],
+		Error:     fields["error"],
+		Detail:    fields["detail"],
+		Server:    fields["server"],
+		Timestamp: timestamp,
+	}
+	if d, err := time.ParseDuration(fields["duration"]); err == nil {
+		result.Duration = d
+	}
+	result.Attempts, _ = strconv.Atoi(fields["attempts"])
+	if fields["results"] != "" {
+		result.ActualResult = strings.Split(fields["results"], ",")
+	}
+	return result, true, nil
+}
+
+func parseJSONLine(line string) (CheckResult, bool, error) {
+	var entry jsonLogEntry
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		return CheckResult{}, false, fmt.Errorf("invalid JSON log line: %v", err)
+	}
+	if entry.Status == "" {
+		return CheckResult{}, false, nil
+	}
+	return CheckResult{
+		Status:       entry.Status,
+		Error:        entry.Error,
+		Detail:       entry.Detail,
+		Server:       entry.Server,
+		Timestamp:    entry.Timestamp,
+		ActualResult: entry.Results,
+		Duration:     entry.Duration,
+		Attempts:     entry.Attempts,
+	}, true, nil
+}
+
+// logfmtValue quotes a value when it is empty or contains spaces, quotes or '='
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\"=\\") || !strconv.CanBackquote(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
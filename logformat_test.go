@@ -0,0 +1,49 @@
+package dnsmonitor
+
+import "testing"
+
+func TestParseLogLineMigratesLegacyStatus(t *testing.T) {
+	tests := []struct {
+		line       string
+		wantStatus string
+		wantError  string
+		wantDetail string
+	}{
+		{
+			line:       "2024-01-01T00:00:00Z\texample.com-A-PASS\t8.8.8.8\t192.0.2.1\t1",
+			wantStatus: "PASS",
+		},
+		{
+			line:       "2024-01-01T00:00:00Z\tmy-site.example.com-SOA-PASS-serial changed from 1 to 2\t8.8.8.8\tns1. admin. 2",
+			wantStatus: "PASS",
+			wantDetail: "serial changed from 1 to 2",
+		},
+		{
+			line:       "2024-01-01T00:00:00Z\texample.com-A-ERROR-lookup example.com on 8.8.8.8: server misbehaving\t8.8.8.8\t",
+			wantStatus: "ERROR",
+			wantError:  "lookup example.com on 8.8.8.8: server misbehaving",
+		},
+		{
+			line:       `ts=2024-01-01T00:00:00Z status="example.com-A-MISMATCH-a returned 1; b returned 2" server="a vs b" results=""`,
+			wantStatus: "MISMATCH",
+			wantDetail: "a returned 1; b returned 2",
+		},
+		{
+			line:       "2024-01-01T00:00:00Z\tFAIL\t8.8.8.8\t192.0.2.1\t1\t\tgot 1 records, want at least 2",
+			wantStatus: "FAIL",
+			wantDetail: "got 1 records, want at least 2",
+		},
+	}
+
+	for _, tt := range tests {
+		result, ok, err := parseLogLine(tt.line)
+		if !ok || err != nil {
+			t.Errorf("parseLogLine(%q) = ok %v, err %v", tt.line, ok, err)
+			continue
+		}
+		if result.Status != tt.wantStatus || result.Error != tt.wantError || result.Detail != tt.wantDetail {
+			t.Errorf("parseLogLine(%q) = %q, %q, %q; want %q, %q, %q", tt.line,
+				result.Status, result.Error, result.Detail, tt.wantStatus, tt.wantError, tt.wantDetail)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package dnsmonitor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyLogFiles lists a history log and its rotated copies (file.log.1,
+// file.log.2, ...) that exist on disk, oldest first
+func historyLogFiles(logFile string) []string {
+	matches, _ := filepath.Glob(logFile + ".*")
+
+	rotated := make(map[int]string)
+	var numbers []int
+	for _, match := range matches {
+		n, err := strconv.Atoi(strings.TrimPrefix(match, logFile+"."))
+		if err != nil || n < 1 {
+			continue
+		}
+		rotated[n] = match
+		numbers = append(numbers, n)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(numbers)))
+
+	var files []string
+	for _, n := range numbers {
+		files = append(files, rotated[n])
+	}
+	if _, err := os.Stat(logFile); err == nil {
+		files = append(files, logFile)
+	}
+	return files
+}
+
+// loadHistoryFiles hydrates a check from its history log and any rotated copies
+func loadHistoryFiles(ctx context.Context, check *DNSCheck, logFile string, retention time.Duration) error {
+	var loaded loadedLines
+	for _, file := range historyLogFiles(logFile) {
+		if err := loadHistoryFromLog(ctx, check, file, retention, &loaded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotateLog shifts file.log to file.log.1, file.log.1 to file.log.2 and so on,
+// deleting copies beyond keep
+func rotateLog(logFile string, keep int) error {
+	for _, file := range historyLogFiles(logFile) {
+		n, err := strconv.Atoi(strings.TrimPrefix(file, logFile+"."))
+		if err == nil && n >= keep {
+			if err := os.Remove(file); err != nil {
+				return fmt.Errorf("error removing old log %s: %v", file, err)
+			}
+		}
+	}
+
+	for n := keep - 1; n >= 1; n-- {
+		from := fmt.Sprintf("%s.%d", logFile, n)
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", logFile, n+1)); err != nil {
+			return fmt.Errorf("error rotating log %s: %v", from, err)
+		}
+	}
+
+	if keep < 1 {
+		return os.Remove(logFile)
+	}
+	if err := os.Rename(logFile, logFile+".1"); err != nil {
+		return fmt.Errorf("error rotating log %s: %v", logFile, err)
+	}
+	return nil
+}
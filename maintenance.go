@@ -0,0 +1,62 @@
+package dnsmonitor
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// MaintenanceWindow is a period of planned work during which checks keep
+// running and recording history but send no notifications. Without checks or
+// labels it covers every check; otherwise a check must be listed by ID or
+// carry all of the labels.
+type MaintenanceWindow struct {
+	Start  time.Time `yaml:"start"`
+	End    time.Time `yaml:"end"`
+	Checks []string  `yaml:"checks"`
+	Labels Labels    `yaml:"labels"`
+	Reason string    `yaml:"reason"`
+}
+
+func (m *MaintenanceWindow) validate() error {
+	if m.Start.IsZero() || m.End.IsZero() {
+		return fmt.Errorf("maintenance window requires start and end")
+	}
+	if !m.End.After(m.Start) {
+		return fmt.Errorf("maintenance window ends at %s, before it starts", m.End.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// covers reports whether the window applies to the check at the given time
+func (m *MaintenanceWindow) covers(check *DNSCheck, at time.Time) bool {
+	if at.Before(m.Start) || !at.Before(m.End) {
+		return false
+	}
+	if len(m.Checks) > 0 && !slices.Contains(m.Checks, check.ID()) {
+		return false
+	}
+	for key, value := range m.Labels {
+		if actual, ok := check.Labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// maintenanceAt returns the maintenance window covering the check at the given
+// time, or nil. Callers must hold c.mu.
+func (c *Config) maintenanceAt(check *DNSCheck, at time.Time) *MaintenanceWindow {
+	for i := range c.Global.MaintenanceWindows {
+		if window := &c.Global.MaintenanceWindows[i]; window.covers(check, at) {
+			return window
+		}
+	}
+	return nil
+}
+
+// Maintenance returns the maintenance window the check is in now, or nil.
+// Callers must hold c.mu.
+func (c *Config) Maintenance(check *DNSCheck) *MaintenanceWindow {
+	return c.maintenanceAt(check, time.Now())
+}
@@ -0,0 +1,2102 @@
+package dnsmonitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type CheckResult struct {
+	Status       string        `json:"status"`
+	Error        string        `json:"error,omitempty"`
+	Detail       string        `json:"detail,omitempty"`
+	Timestamp    time.Time     `json:"timestamp"`
+	ActualResult []string      `json:"actual_result"`
+	Server       string        `json:"server"`
+	Duration     time.Duration `json:"duration"`
+	Attempts     int           `json:"attempts,omitempty"`
+
+	// TTL of each record in seconds, keyed by the record, with capture_ttl
+	TTLs map[string]uint32 `json:"ttls,omitempty"`
+
+	// EDNS Client Subnet the query was sent with, with client_subnet
+	ClientSubnet string `json:"client_subnet,omitempty"`
+	// Response code of the answer, for checks with expected_rcode
+	Rcode string `json:"rcode,omitempty"`
+
+	// With dedupe_history, one entry stands for Count consecutive identical
+	// polls, the last of which ran at LastSeen
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+	Count    int        `json:"count,omitempty"`
+}
+
+// Describe renders the status with its error or detail text, if any
+func (r CheckResult) Describe() string {
+	switch {
+	case r.Error != "":
+		return r.Status + ": " + r.Error
+	case r.Detail != "":
+		return r.Status + ": " + r.Detail
+	}
+	return r.Status
+}
+
+type DNSCheck struct {
+	Name                  string        `yaml:"name"`
+	Template              string        `yaml:"template"`
+	Domain                string        `yaml:"domain"`
+	Subdomains            []string      `yaml:"subdomains"`
+	Type                  string        `yaml:"type"`
+	Expected              string        `yaml:"expected"`
+	MatchMode             string        `yaml:"match_mode"`
+	Negate                bool          `yaml:"negate"`
+	Propagation           bool          `yaml:"propagation"`
+	Baseline              bool          `yaml:"baseline"`
+	Interval              time.Duration `yaml:"interval"`
+	Timeout               time.Duration `yaml:"timeout"`
+	Retries               int           `yaml:"retries"`
+	MaxHistoryEntries     int           `yaml:"max_history_entries"`
+	TransientErrors       []string      `yaml:"transient_errors"`
+	RequireResolutionOnly bool          `yaml:"require_resolution_only"`
+	Enabled               *bool         `yaml:"enabled"`
+	TCP                   bool          `yaml:"tcp"`
+	Command               []string      `yaml:"command"`
+	DNSSEC                bool          `yaml:"dnssec"`
+	MinResults            int           `yaml:"min_results"`
+	MaxResults            int           `yaml:"max_results"`
+	Validate              string        `yaml:"validate"`
+	MinPolicy             string        `yaml:"min_policy"`
+	CaptureTTL            bool          `yaml:"capture_ttl"`
+	SortRecords           bool          `yaml:"sort_records"`
+	TTLMin                time.Duration `yaml:"ttl_min"`
+	TTLMax                time.Duration `yaml:"ttl_max"`
+	ClientSubnet          string        `yaml:"client_subnet"`
+	ExpectedRcode         string        `yaml:"expected_rcode"`
+	Class                 string        `yaml:"class"`
+	Description           string        `yaml:"description"`
+	Runbook               string        `yaml:"runbook"`
+	Labels                Labels        `yaml:"labels"`
+	SLO                   *SLOConfig    `yaml:"slo"`
+	BurnRate              float64       `yaml:"-"`
+	Discovered            bool          `yaml:"-"`
+	Runtime               bool          `yaml:"-"`
+	Status                string        `yaml:"-"`
+	LastCheck             time.Time     `yaml:"-"`
+	History               []CheckResult `json:"-" yaml:"-"`
+	Annotations           []Annotation  `json:"-" yaml:"-"`
+	historyLock           sync.RWMutex
+	logLock               sync.Mutex
+	zoneRecords           []string
+	baseline              []string
+	expectedRegexp        *regexp.Regexp
+	asciiDomain           string
+	clientSubnet          *net.IPNet
+	qclass                uint16
+	sloBurning            bool
+	dedupeHistory         bool
+	source                string
+
+	// Scheduling state, guarded by the scheduler's lock
+	nextRun     time.Time
+	queueIndex  int
+	scheduled   bool
+	scheduledAt time.Time
+
+	// Set once the check is dropped by the API, a reload or discovery, so a
+	// poll that was in flight neither records its result nor recreates its log
+	removed atomic.Bool
+}
+
+type Config struct {
+	Global struct {
+		DNSServer          string               `yaml:"dns_server"`
+		SecondaryDNSServer string               `yaml:"secondary_dns_server"`
+		DNSServers         []string             `yaml:"dns_servers"`
+		DefaultInterval    time.Duration        `yaml:"default_interval"`
+		Timeout            time.Duration        `yaml:"timeout"`
+		Jitter             *time.Duration       `yaml:"jitter"`
+		MaxConcurrent      int                  `yaml:"max_concurrent_checks"`
+		Retries            int                  `yaml:"retries"`
+		LogDir             string               `yaml:"log_dir"`
+		HistoryRetention   time.Duration        `yaml:"history_retention"`
+		DedupeHistory      bool                 `yaml:"dedupe_history"`
+		CaptureTTL         bool                 `yaml:"capture_ttl"`
+		SortRecords        bool                 `yaml:"sort_records"`
+		MaxHistoryEntries  int                  `yaml:"max_history_entries"`
+		LogFormat          string               `yaml:"log_format"`
+		LogMaxSizeMB       int                  `yaml:"log_max_size_mb"`
+		LogMaxFiles        int                  `yaml:"log_max_files"`
+		SLO                *SLOConfig           `yaml:"slo"`
+		UptimeWindows      []time.Duration      `yaml:"uptime_windows"`
+		ResolverMode       string               `yaml:"resolver_mode"`
+		TLSServerName      string               `yaml:"tls_server_name"`
+		StrictResolver     bool                 `yaml:"strict_resolver"`
+		Port               string               `yaml:"port"`
+		ListenAddr         string               `yaml:"listen_addr"`
+		TLSCert            string               `yaml:"tls_cert"`
+		TLSKey             string               `yaml:"tls_key"`
+		HTTPRedirectAddr   string               `yaml:"http_redirect_addr"`
+		TemplateFile       string               `yaml:"template_file"`
+		TemplateWatch      bool                 `yaml:"template_watch"`
+		StatsD             StatsDConfig         `yaml:"statsd"`
+		TransientErrors    []string             `yaml:"transient_errors"`
+		UnhealthyThreshold float64              `yaml:"unhealthy_threshold"`
+		APIToken           string               `yaml:"api_token"`
+		Auth               AuthConfig           `yaml:"auth"`
+		ZoneFile           string               `yaml:"zone_file"`
+		ZoneOrigin         string               `yaml:"zone_origin"`
+		LeaderElection     LeaderElectionConfig `yaml:"leader_election"`
+		OTel               OTelConfig           `yaml:"otel"`
+		Discover           *DiscoverConfig      `yaml:"discover"`
+		SMTP               *SMTPConfig          `yaml:"smtp"`
+		SlackWebhookURL    string               `yaml:"slack_webhook_url"`
+		Webhook            *WebhookConfig       `yaml:"webhook"`
+		MaintenanceWindows []MaintenanceWindow  `yaml:"maintenance_windows"`
+	} `yaml:"global"`
+	// Include lists further files holding checks, see loadIncludes
+	Include []string `yaml:"include"`
+	// templates are the named check templates, see parseTemplates
+	templates map[string]*yaml.Node
+
+	Checks []*DNSCheck `yaml:"checks"`
+	mu     sync.RWMutex
+	statsd *statsdClient
+	otel   *otelExporter
+	leader *leaderElector
+
+	servers       []string
+	resolvers     []Resolver
+	tcpResolvers  []Resolver
+	scheduler     *scheduler
+	monitors      sync.WaitGroup
+	logWrites     sync.WaitGroup
+	notifiers     []notifier
+	notifications sync.WaitGroup
+	ctx           context.Context
+
+	// monitoring is true while the check goroutines are running
+	monitoring atomic.Bool
+
+	// listenOverride is the listen address given on the command line, which
+	// takes precedence over global.listen_addr and global.port
+	listenOverride string
+
+	// tlsCert is the certificate served with tls_cert, replaced on reload
+	tlsCert atomic.Pointer[tls.Certificate]
+}
+
+func (c *Config) updateStatus(check *DNSCheck, result CheckResult) {
+	c.mu.Lock()
+	if check.removed.Load() {
+		c.mu.Unlock()
+		return
+	}
+	check.Status = result.Status
+	check.LastCheck = result.Timestamp
+
+	// Update history
+	check.historyLock.Lock()
+	alert, changed := transition(check, result)
+	check.appendResult(result)
+
+	// Keep only the retention window of history
+	cutoff := time.Now().Add(-c.Global.HistoryRetention)
+	var newHistory []CheckResult
+	for _, hist := range check.History {
+		if hist.seen().After(cutoff) {
+			newHistory = append(newHistory, hist)
+		}
+	}
+	check.History = newHistory
+	check.trimHistory()
+	sloAlert, sloChanged := check.updateBurnRate(result)
+	burnRate := check.BurnRate
+	check.historyLock.Unlock()
+
+	var candidates, alerts []Alert
+	if changed {
+		candidates = append(candidates, alert)
+	}
+	if sloChanged {
+		candidates = append(candidates, sloAlert)
+	}
+	for _, alert := range candidates {
+		if window := c.maintenanceAt(check, result.Timestamp); window != nil {
+			log.Printf("Suppressed %s alert for %s during maintenance until %s", alert.Event, check.ID(), window.End.Format(time.RFC3339))
+		} else {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	// Under the lock so a check being removed doesn't get its series back
+	recordPrometheus(check, result)
+
+	// Save to log file; shutdown waits for pending writes
+	if c.Global.LogDir != "" {
+		c.logWrites.Add(1)
+		go func() {
+			defer c.logWrites.Done()
+			c.saveCheckToLog(check, result)
+		}()
+	}
+	c.mu.Unlock()
+
+	// Notifiers and exporters talk to the network, so they run without the
+	// lock and only use the check's settings and the values copied above
+	for _, alert := range alerts {
+		c.notify(alert)
+	}
+	if c.statsd != nil {
+		c.statsd.sendCheckResult(check, result, burnRate)
+	}
+	if c.otel != nil {
+		c.otel.recordCheckResult(check, result, burnRate)
+	}
+}
+
+// ServerResults returns the latest result from each server in configuration
+// order, followed by the latest MISMATCH result if the servers currently disagree
+// or, for propagation checks, the latest PROPAGATING or PROPAGATED result
+func (c *Config) ServerResults(check *DNSCheck) []CheckResult {
+	check.historyLock.RLock()
+	defer check.historyLock.RUnlock()
+
+	servers := c.Global.DNSServers
+	if len(servers) == 0 {
+		servers = []string{""}
+	}
+
+	var results []CheckResult
+	for _, server := range servers {
+		for i := len(check.History) - 1; i >= 0; i-- {
+			if check.History[i].Server == server {
+				results = append(results, check.History[i])
+				break
+			}
+		}
+	}
+	if latest := lastCheck(check.History); latest != nil {
+		switch statusClass(latest.Status) {
+		case "MISMATCH", "PROPAGATING", "PROPAGATED":
+			results = append(results, *latest)
+		}
+	}
+	return results
+}
+
+// Mismatched lists the checks whose servers currently disagree. Callers must hold c.mu.
+func (c *Config) Mismatched() []*DNSCheck {
+	var checks []*DNSCheck
+	for _, check := range c.Checks {
+		if statusClass(check.Status) == "MISMATCH" {
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
+
+// ID is the check's stable identifier: its name, or "domain-type" when unnamed
+func (check *DNSCheck) ID() string {
+	if check.Name != "" {
+		return check.Name
+	}
+	return fmt.Sprintf("%s-%s", check.Domain, check.Type)
+}
+
+// findCheckByName returns the check with the given name or ID, or nil.
+// Callers must hold c.mu.
+func (c *Config) findCheckByName(name string) *DNSCheck {
+	for _, check := range c.Checks {
+		if check.ID() == name {
+			return check
+		}
+	}
+	return nil
+}
+
+// lookupError is why an API request does not identify a single check, with
+// the status to answer it with
+type lookupError struct {
+	status int
+	msg    string
+}
+
+func (e *lookupError) Error() string { return e.msg }
+
+// lookupCheck finds the check an API request refers to: by its ID in the
+// {id} path segment or ?name=, or by domain and type given in the path or as
+// ?domain=&type=. Named checks may share a domain and type, in which case the
+// request must give the ID. Callers must hold c.mu.
+func (c *Config) lookupCheck(r *http.Request) (*DNSCheck, *lookupError) {
+	query := r.URL.Query()
+	name := r.PathValue("id")
+	if name == "" {
+		name = query.Get("name")
+	}
+	if name != "" {
+		if check := c.findCheckByName(name); check != nil {
+			return check, nil
+		}
+		return nil, &lookupError{http.StatusNotFound, fmt.Sprintf("no check found for %s", name)}
+	}
+
+	domain, recordType := r.PathValue("domain"), r.PathValue("type")
+	if domain == "" {
+		domain, recordType = query.Get("domain"), query.Get("type")
+	}
+	var matches []string
+	var check *DNSCheck
+	for _, candidate := range c.Checks {
+		if strings.EqualFold(candidate.Domain, domain) && strings.EqualFold(candidate.Type, recordType) {
+			check = candidate
+			matches = append(matches, candidate.ID())
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, &lookupError{http.StatusNotFound, fmt.Sprintf("no check found for %s (%s)", domain, recordType)}
+	case 1:
+		return check, nil
+	}
+	return nil, &lookupError{http.StatusConflict, fmt.Sprintf("%d checks match %s (%s); give the ID of one of %s",
+		len(matches), domain, recordType, strings.Join(matches, ", "))}
+}
+
+// failingChecks counts the enabled checks whose latest status is neither PASS
+// nor PENDING. Callers must hold c.mu.
+func (c *Config) failingChecks() (failing, total int) {
+	for _, check := range c.Checks {
+		if check.Disabled() {
+			continue
+		}
+		total++
+		if class := statusClass(check.Status); class != "PASS" && class != "PENDING" {
+			failing++
+		}
+	}
+	return failing, total
+}
+
+func historyLogFile(logDir string, check *DNSCheck) string {
+	return filepath.Join(logDir, check.ID()+".log")
+}
+
+// isLeader reports whether this instance should actively poll; always true
+// unless leader election is configured
+func (c *Config) isLeader() bool {
+	return c.leader == nil || c.leader.leader.Load()
+}
+
+// Role describes this instance's leader election role for the status page
+func (c *Config) Role() string {
+	if c.leader == nil {
+		return ""
+	}
+	if c.isLeader() {
+		return "leader"
+	}
+	return "follower"
+}
+
+// refreshFromLog reloads a check's history from the shared log directory so
+// followers can mirror the results written by the leader
+func (c *Config) refreshFromLog(check *DNSCheck) {
+	logFile := historyLogFile(c.Global.LogDir, check)
+
+	scratch := DNSCheck{dedupeHistory: check.dedupeHistory, MaxHistoryEntries: check.MaxHistoryEntries}
+	if err := loadHistoryFiles(c.ctx, &scratch, logFile, c.Global.HistoryRetention); err != nil {
+		log.Printf("Warning: Failed to refresh history from %s: %v", logFile, err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	check.historyLock.Lock()
+	check.History = scratch.History
+	check.historyLock.Unlock()
+
+	if last := lastCheck(scratch.History); last != nil {
+		check.Status = last.Status
+		check.LastCheck = last.Timestamp
+	}
+}
+
+func (c *Config) saveCheckToLog(check *DNSCheck, result CheckResult) {
+	filename := historyLogFile(c.Global.LogDir, check)
+	logEntry := formatLogEntry(check, result, c.Global.LogFormat)
+
+	// Create log directory if it doesn't exist
+	if err := os.MkdirAll(c.Global.LogDir, 0755); err != nil {
+		log.Printf("Error creating log directory: %v", err)
+		return
+	}
+
+	// Writes for the same check run concurrently, and rotation renames the file
+	check.logLock.Lock()
+	defer check.logLock.Unlock()
+	if check.removed.Load() {
+		return
+	}
+
+	maxSize := int64(c.Global.LogMaxSizeMB) << 20
+	if info, err := os.Stat(filename); err == nil && info.Size()+int64(len(logEntry)) > maxSize {
+		if err := rotateLog(filename, c.Global.LogMaxFiles); err != nil {
+			log.Printf("Error rotating log file: %v", err)
+		}
+	}
+
+	// Open log file in append mode
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		log.Printf("Error opening log file: %v", err)
+		return
+	}
+	// Changed this line to handle Close() error
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("Error closing log file: %v", err)
+		}
+	}()
+
+	// Start on a new line if a crash cut the last entry short, so it doesn't
+	// run into this one
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			logEntry = "\n" + logEntry
+		}
+	}
+
+	if _, err := f.WriteString(logEntry); err != nil {
+		log.Printf("Error writing to log file: %v", err)
+	}
+}
+
+var validCheckName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// supportedTypes are the record types lookupRecords knows how to query
+var supportedTypes = map[string]bool{
+	"A": true, "CNAME": true, "NS": true, "TXT": true, "MX": true,
+	"SRV": true, "SOA": true, "PTR": true, "CAA": true, "CHAIN": true,
+	"EXEC": true,
+}
+
+// validateCheck reports every problem with a single check's own settings
+func validateCheck(check *DNSCheck) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("check %s (%s) %s", check.Domain, check.Type, fmt.Sprintf(format, args...)))
+	}
+
+	if check.Domain == "" {
+		fail("has no domain")
+	}
+	if name, err := asciiDomain(check.Domain); err != nil {
+		fail("has an invalid internationalized domain: %v", err)
+	} else if name != check.Domain {
+		check.asciiDomain = name
+	}
+	if !supportedTypes[check.Type] {
+		fail("has unsupported type %q", check.Type)
+	}
+
+	// Names end up in file names and URLs, and IDs must be unique to tell checks apart
+	if check.Name != "" && !validCheckName.MatchString(check.Name) {
+		fail("has invalid name %q; use letters, digits, '.', '_' and '-'", check.Name)
+	}
+
+	// An empty expected value matches every record, so require an explicit opt-in
+	if check.Expected == "" && !check.RequireResolutionOnly && check.Validate == "" && !check.Baseline && check.ExpectedRcode == "" {
+		fail("has no expected value; set require_resolution_only: true to only check that it resolves")
+	}
+	if check.Baseline && check.Expected != "" {
+		fail("sets both expected and baseline; a baseline check compares against its captured answer instead")
+	}
+	if check.Negate && (check.Expected == "" || check.RequireResolutionOnly || check.Baseline) {
+		fail("sets negate, which needs an expected value and no require_resolution_only or baseline")
+	}
+	if check.Propagation && (check.Expected == "" || check.RequireResolutionOnly || check.Baseline || check.Negate) {
+		fail("sets propagation, which needs an expected value and no require_resolution_only, baseline or negate")
+	}
+	if err := validateCheckPolicy(check); err != nil {
+		fail("has an invalid policy: %v", err)
+	}
+	switch check.MatchMode {
+	case "":
+		check.MatchMode = "contains"
+	case "contains", "exact":
+	case "regex":
+		re, err := regexp.Compile(check.Expected)
+		if err != nil {
+			fail("has an invalid expected regex %q: %v", check.Expected, err)
+		}
+		check.expectedRegexp = re
+	default:
+		fail("has invalid match_mode %q, must be contains, exact or regex", check.MatchMode)
+	}
+	if check.Interval < 0 {
+		fail("has a negative interval")
+	}
+	if check.Timeout < 0 {
+		fail("has a negative timeout")
+	}
+	if check.Retries < 0 {
+		fail("has negative retries")
+	}
+	if check.MaxHistoryEntries < 0 {
+		fail("has a negative max_history_entries")
+	}
+	if check.ClientSubnet != "" {
+		_, subnet, err := net.ParseCIDR(check.ClientSubnet)
+		if err != nil {
+			fail("has an invalid client_subnet %q: %v", check.ClientSubnet, err)
+		}
+		if check.Type == "CHAIN" {
+			fail("sets client_subnet, which CHAIN checks do not support")
+		}
+		check.clientSubnet = subnet
+	}
+	if check.ExpectedRcode != "" {
+		check.ExpectedRcode = strings.ToUpper(check.ExpectedRcode)
+		if !validRcode(check.ExpectedRcode) {
+			fail("has an invalid expected_rcode %q, must be an RCODE such as NOERROR, NXDOMAIN or REFUSED", check.ExpectedRcode)
+		}
+		if check.Type == "CHAIN" {
+			fail("sets expected_rcode, which CHAIN checks do not support")
+		}
+	}
+	if check.Class != "" {
+		if class, qclass, ok := queryClass(check.Class); ok {
+			check.Class, check.qclass = class, qclass
+		} else {
+			fail("has an invalid class %q, must be IN, CH (CHAOS) or HS (HESIOD)", check.Class)
+		}
+		if check.qclass != 0 && (check.Type == "CHAIN" || check.Type == "PTR" || check.Type == "EXEC" || check.DNSSEC) {
+			fail("sets class %s, which %s checks do not support", check.Class, check.Type)
+		}
+	}
+	if check.Type == "EXEC" {
+		if len(check.Command) == 0 || check.Command[0] == "" {
+			fail("is an EXEC check without a command")
+		}
+		if !validExecDomain.MatchString(check.queryName()) {
+			fail("passes %q to its command, which is not a plain host name", check.Domain)
+		}
+		if check.ClientSubnet != "" || check.ExpectedRcode != "" || check.DNSSEC || check.CaptureTTL ||
+			check.TTLMin > 0 || check.TTLMax > 0 || check.TCP {
+			fail("sets DNS query options, which EXEC checks do not support")
+		}
+	} else if len(check.Command) > 0 {
+		fail("sets command, which only EXEC checks use")
+	}
+	if check.TTLMin < 0 || check.TTLMax < 0 || (check.TTLMax > 0 && check.TTLMax < check.TTLMin) {
+		fail("has an invalid TTL range: ttl_min %s, ttl_max %s", check.TTLMin, check.TTLMax)
+	}
+	for key := range check.Labels {
+		// Keys are matched as "key:value" in filters, so they cannot contain a colon
+		if key == "" || strings.Contains(key, ":") {
+			fail("has invalid label key %q", key)
+		}
+	}
+	if check.Runbook != "" {
+		if u, err := url.Parse(check.Runbook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("has an invalid runbook %q, must be an http or https URL", check.Runbook)
+		}
+	}
+	if check.MinResults < 0 || check.MaxResults < 0 ||
+		(check.MaxResults > 0 && check.MaxResults < check.MinResults) {
+		fail("has an invalid result range: min_results %d, max_results %d", check.MinResults, check.MaxResults)
+	}
+	return errs
+}
+
+// validateConfig checks the global settings and every check, returning a
+// single error that lists all problems found so they can be fixed in one pass.
+// errs are problems LoadConfig already found while reading the global settings.
+func validateConfig(config *Config, errs ...error) error {
+	if config.Global.DefaultInterval < 0 {
+		errs = append(errs, fmt.Errorf("default_interval must not be negative"))
+	}
+	if config.Global.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout must not be negative"))
+	}
+	if config.Global.HistoryRetention < 0 {
+		errs = append(errs, fmt.Errorf("history_retention must not be negative"))
+	}
+	if config.Global.Jitter != nil && *config.Global.Jitter < 0 {
+		errs = append(errs, fmt.Errorf("jitter must not be negative"))
+	}
+	if config.Global.Retries < 0 {
+		errs = append(errs, fmt.Errorf("retries must not be negative"))
+	}
+	if config.Global.MaxHistoryEntries < 0 {
+		errs = append(errs, fmt.Errorf("max_history_entries must not be negative"))
+	}
+	if config.Global.MaxConcurrent < 0 {
+		errs = append(errs, fmt.Errorf("max_concurrent_checks must not be negative"))
+	}
+	if err := config.Global.Auth.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid auth: %v", err))
+	}
+	for i := range config.Global.MaintenanceWindows {
+		if err := config.Global.MaintenanceWindows[i].validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	sources := make(map[string]string)
+	for _, check := range config.Checks {
+		errs = append(errs, validateCheck(check)...)
+		id := check.ID()
+		if source, ok := sources[id]; ok {
+			if source != check.source {
+				errs = append(errs, fmt.Errorf("duplicate check %q in %s and %s; give each check a unique name", id, source, check.source))
+			} else {
+				errs = append(errs, fmt.Errorf("duplicate check %q; give each check a unique name", id))
+			}
+		}
+		sources[id] = check.source
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	problems := make([]string, len(errs))
+	for i, err := range errs {
+		problems[i] = "  - " + err.Error()
+	}
+	return fmt.Errorf("invalid config, %d problem(s):\n%s", len(errs), strings.Join(problems, "\n"))
+}
+
+// initCheck applies global defaults to a check and hydrates its history and
+// annotations from the log directory
+func (c *Config) initCheck(check *DNSCheck) error {
+	if check.Interval == 0 {
+		check.Interval = c.Global.DefaultInterval
+	}
+	if check.Timeout == 0 {
+		check.Timeout = c.Global.Timeout
+	}
+	if check.Retries == 0 {
+		check.Retries = c.Global.Retries
+	}
+	if check.MaxHistoryEntries == 0 {
+		check.MaxHistoryEntries = c.Global.MaxHistoryEntries
+	}
+	if c.Global.SortRecords {
+		check.SortRecords = true
+	}
+	// TTL assertions need the TTLs
+	if (c.Global.CaptureTTL && check.Type != "EXEC") || check.TTLMin > 0 || check.TTLMax > 0 {
+		check.CaptureTTL = true
+	}
+	if len(check.TransientErrors) == 0 {
+		check.TransientErrors = c.Global.TransientErrors
+	}
+	if check.SLO == nil && c.Global.SLO != nil {
+		slo := *c.Global.SLO
+		check.SLO = &slo
+	}
+	if check.SLO != nil {
+		if err := check.SLO.validate(); err != nil {
+			return fmt.Errorf("check %s: %v", check.ID(), err)
+		}
+	}
+	check.Status = "PENDING"
+	if check.Disabled() {
+		check.Status = "DISABLED"
+	}
+	check.History = make([]CheckResult, 0)
+	check.dedupeHistory = c.Global.DedupeHistory
+
+	// Loading is cut short when the monitor (or the config load) is cancelled
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	logFile := historyLogFile(c.Global.LogDir, check)
+	if err := loadHistoryFiles(ctx, check, logFile, c.Global.HistoryRetention); err != nil {
+		// Log the error but continue loading config
+		log.Printf("Warning: Failed to load history for %s-%s: %v",
+			check.Domain, check.Type, err)
+	}
+
+	notesFile := annotationFile(c.Global.LogDir, check)
+	if _, err := os.Stat(notesFile); err == nil {
+		if err := loadAnnotations(check, notesFile, c.Global.HistoryRetention); err != nil {
+			log.Printf("Warning: Failed to load annotations for %s-%s: %v",
+				check.Domain, check.Type, err)
+		}
+	}
+
+	if check.Baseline {
+		baselineFile := baselineFile(c.Global.LogDir, check)
+		if _, err := os.Stat(baselineFile); err == nil {
+			if err := loadBaseline(check, baselineFile); err != nil {
+				log.Printf("Warning: Failed to load baseline for %s-%s: %v",
+					check.Domain, check.Type, err)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadConfig reads and validates a config file and the files it includes, and
+// loads each check's history from the log directory
+func LoadConfig(filename string) (*Config, error) {
+	return LoadConfigContext(context.Background(), filename)
+}
+
+// LoadConfigContext is LoadConfig with a context that cancels loading history,
+// which can take a while with large logs
+func LoadConfigContext(ctx context.Context, filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing YAML: %v", err)
+	}
+	if err := expandEnv(&doc); err != nil {
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+	var config Config
+	if config.templates, err = parseTemplates(&doc); err != nil {
+		return nil, fmt.Errorf("error in templates: %v", err)
+	}
+	if err := applyTemplates(&doc, config.templates); err != nil {
+		return nil, fmt.Errorf("error applying templates: %v", err)
+	}
+	if err := doc.Decode(&config); err != nil {
+		return nil, fmt.Errorf("error parsing YAML: %v", err)
+	}
+
+	for _, check := range config.Checks {
+		check.source = filename
+	}
+	if err := config.loadIncludes(filename); err != nil {
+		return nil, err
+	}
+	config.Checks = expandSubdomains(config.Checks)
+
+	if config.Global.DefaultInterval == 0 {
+		config.Global.DefaultInterval = 5 * time.Minute
+	}
+	if config.Global.Timeout == 0 {
+		config.Global.Timeout = 5 * time.Second
+	}
+	if config.Global.MaxConcurrent == 0 {
+		config.Global.MaxConcurrent = 16
+	}
+	if config.Global.Jitter == nil {
+		jitter := 10 * time.Second
+		config.Global.Jitter = &jitter
+	}
+	if len(config.Global.UptimeWindows) == 0 {
+		config.Global.UptimeWindows = []time.Duration{24 * time.Hour, 7 * 24 * time.Hour, 30 * 24 * time.Hour}
+	}
+	if config.Global.LogDir == "" {
+		config.Global.LogDir = "logs"
+	}
+	if config.Global.HistoryRetention == 0 {
+		config.Global.HistoryRetention = 30 * 24 * time.Hour
+	}
+	if config.Global.LogMaxSizeMB <= 0 {
+		config.Global.LogMaxSizeMB = 10
+	}
+	if config.Global.LogMaxFiles <= 0 {
+		config.Global.LogMaxFiles = 5
+	}
+	if config.Global.Port == "" {
+		config.Global.Port = "8080"
+	}
+
+	// Problems with the global settings are collected with those of the
+	// checks so one load reports all of them
+	var errs []error
+	switch config.Global.LogFormat {
+	case "", "text":
+		config.Global.LogFormat = "tsv"
+	case "tsv", "logfmt", "json":
+	default:
+		errs = append(errs, fmt.Errorf("invalid log_format %q, must be tsv (or text), logfmt or json", config.Global.LogFormat))
+	}
+
+	switch config.Global.ResolverMode {
+	case "", "udp":
+		config.Global.ResolverMode = "udp"
+	case "tcp", "doh", "dot":
+	default:
+		errs = append(errs, fmt.Errorf("invalid resolver_mode %q, must be udp, tcp, doh or dot", config.Global.ResolverMode))
+	}
+
+	if !strings.HasPrefix(config.Global.Port, ":") {
+		config.Global.Port = ":" + config.Global.Port
+	}
+	if config.Global.ListenAddr != "" {
+		if err := validateListenAddr(config.Global.ListenAddr); err != nil {
+			errs = append(errs, fmt.Errorf("invalid listen_addr: %v", err))
+		}
+	}
+	if err := config.loadTLS(); err != nil {
+		errs = append(errs, err)
+	}
+
+	// dns_server and secondary_dns_server are merged into the front of dns_servers
+	var servers []string
+	seen := make(map[string]bool)
+	for _, server := range append([]string{config.Global.DNSServer, config.Global.SecondaryDNSServer}, config.Global.DNSServers...) {
+		if server == "" || seen[server] {
+			continue
+		}
+		if err := validateServer(config.Global.ResolverMode, server); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		seen[server] = true
+		servers = append(servers, server)
+	}
+	config.Global.DNSServers = servers
+	if config.Global.DNSServer == "" && len(servers) > 0 {
+		config.Global.DNSServer = servers[0]
+	}
+	if (config.Global.ResolverMode == "doh" || config.Global.ResolverMode == "dot") && len(servers) == 0 {
+		errs = append(errs, fmt.Errorf("resolver_mode %s requires dns_server", config.Global.ResolverMode))
+	}
+
+	if config.Global.SMTP != nil {
+		if err := config.Global.SMTP.validate(); err != nil {
+			errs = append(errs, err)
+		} else {
+			config.notifiers = append(config.notifiers, newSMTPNotifier(*config.Global.SMTP))
+		}
+	}
+	if config.Global.SlackWebhookURL != "" {
+		config.notifiers = append(config.notifiers, newSlackNotifier(config.Global.SlackWebhookURL))
+	}
+	if config.Global.Webhook != nil {
+		if err := config.Global.Webhook.validate(); err != nil {
+			errs = append(errs, err)
+		} else if webhook, err := newWebhookNotifier(*config.Global.Webhook); err != nil {
+			errs = append(errs, err)
+		} else {
+			config.notifiers = append(config.notifiers, webhook)
+		}
+	}
+
+	if config.Global.Discover != nil {
+		// Zone transfers and seed lookups go straight to a plain DNS server
+		plainServer := ""
+		if config.Global.ResolverMode == "udp" || config.Global.ResolverMode == "tcp" {
+			plainServer = config.Global.DNSServer
+		}
+		if err := config.Global.Discover.validate(plainServer); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := validateConfig(&config, errs...); err != nil {
+		return nil, err
+	}
+
+	var zone map[string][]string
+	if config.Global.ZoneFile != "" {
+		zone, err = loadZoneFile(config.Global.ZoneFile, config.Global.ZoneOrigin)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	config.ctx = ctx
+	for _, check := range config.Checks {
+		check.zoneRecords = zone[zoneKey(check.Domain, check.Type)]
+		if err := config.initCheck(check); err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("error loading history: %v", err)
+		}
+	}
+
+	return &config, nil
+}
+
+// maxLogLine is the longest history log line that can be loaded
+const maxLogLine = 1 << 20
+
+// loadHistoryFromLog appends the results in logFile that fall within the
+// retention window to the check's history. The file is streamed rather than
+// read whole: lines older than the window are skipped with a binary search,
+// and the history is trimmed to max_history_entries as it grows. Loading stops
+// when ctx is cancelled. Lines already in loaded are skipped, as is a last line
+// left unfinished by a crash.
+func loadHistoryFromLog(ctx context.Context, check *DNSCheck, logFile string, retention time.Duration, loaded *loadedLines) error {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return fmt.Errorf("error reading history file %s: %v", logFile, err)
+	}
+	defer f.Close()
+
+	cutoff := time.Now().Add(-retention)
+	if err := seekToCutoff(f, cutoff); err != nil {
+		return fmt.Errorf("error reading history file %s: %v", logFile, err)
+	}
+
+	check.historyLock.Lock()
+	defer check.historyLock.Unlock() // Make sure we always unlock
+
+	// Every entry is written with its newline, so text after the last one is
+	// an interrupted write
+	var partial bool
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLogLine)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) > 0 && !bytes.Contains(data, []byte("\n")) {
+			partial = true
+			return len(data), nil, nil
+		}
+		return bufio.ScanLines(data, atEOF)
+	})
+
+	var duplicates int
+	for n := 0; scanner.Scan(); n++ {
+		if n%1024 == 0 && ctx.Err() != nil {
+			return fmt.Errorf("error reading history file %s: %v", logFile, ctx.Err())
+		}
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		// Lines are parsed individually so logs that changed format still load
+		result, ok, err := parseLogLine(line)
+		if err != nil {
+			// Log the error but continue processing other lines
+			log.Printf("Error parsing timestamp in log file %s: %v", logFile, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if result.Timestamp.After(cutoff) {
+			if !loaded.add(result) {
+				duplicates++
+				continue
+			}
+			check.appendResult(result)
+			if limit := check.MaxHistoryEntries; limit > 0 && len(check.History) >= 2*limit {
+				check.trimHistory()
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading history file %s: %v", logFile, err)
+	}
+	check.trimHistory()
+
+	if duplicates > 0 {
+		log.Printf("Warning: Skipped %d duplicate lines in %s", duplicates, logFile)
+	}
+	if partial {
+		log.Printf("Warning: Skipped an incomplete last line in %s, likely from an unclean shutdown", logFile)
+	}
+	return nil
+}
+
+// loadedLines remembers the results loaded for the latest timestamp, so that a
+// line written twice is loaded once. Logs are in time order, so a duplicate
+// shares the latest timestamp with the line it repeats.
+type loadedLines struct {
+	latest time.Time
+	keys   map[loadedLine]bool
+}
+
+type loadedLine struct {
+	timestamp      int64
+	status, server string
+}
+
+// add reports whether result has not been loaded yet, and remembers it
+func (l *loadedLines) add(result CheckResult) bool {
+	if l.keys == nil || result.Timestamp.After(l.latest) {
+		l.latest = result.Timestamp
+		l.keys = make(map[loadedLine]bool)
+	}
+	key := loadedLine{result.Timestamp.UnixNano(), result.Status, result.Server}
+	if l.keys[key] {
+		return false
+	}
+	l.keys[key] = true
+	return true
+}
+
+// seekToCutoff positions f at the start of a line before the first line
+// logged after cutoff, so loading a large log only parses its tail. Lines are
+// appended in time order; a line that cannot be parsed keeps the search on the
+// safe side, reading more of the file rather than less.
+func seekToCutoff(f *os.File, cutoff time.Time) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Narrow down to a window small enough to just scan
+	lo, hi := int64(0), info.Size()
+	for hi-lo > 64*1024 {
+		mid := lo + (hi-lo)/2
+		_, line, err := nextLine(f, mid)
+		if err != nil {
+			return err
+		}
+		if result, ok, err := parseLogLine(line); err == nil && ok && result.Timestamp.Before(cutoff) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	start, _, err := nextLine(f, lo)
+	if err != nil {
+		return err
+	}
+	_, err = f.Seek(start, io.SeekStart)
+	return err
+}
+
+// nextLine returns the first line of f that starts at or after offset, and
+// where it starts. The line is empty at the end of the file.
+func nextLine(f *os.File, offset int64) (int64, string, error) {
+	r := bufio.NewReader(io.NewSectionReader(f, offset, math.MaxInt64-offset))
+	start := offset
+	if offset > 0 {
+		// Finish the line offset falls in, unless offset is the start of a line
+		var prev [1]byte
+		if _, err := f.ReadAt(prev[:], offset-1); err != nil {
+			return 0, "", err
+		}
+		if prev[0] != '\n' {
+			skipped, err := r.ReadString('\n')
+			if err == io.EOF {
+				return offset + int64(len(skipped)), "", nil
+			}
+			if err != nil {
+				return 0, "", err
+			}
+			start += int64(len(skipped))
+		}
+	}
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, "", err
+	}
+	return start, strings.TrimSuffix(line, "\n"), nil
+}
+
+// createResolver returns a resolver for the given server, or the system
+// resolver when it is empty. Queries go over UDP and are retried over TCP when
+// the answer is truncated; forceTCP sends every query over TCP.
+func createResolver(dnsServer string, forceTCP bool) *net.Resolver {
+	if dnsServer == "" && !forceTCP {
+		return net.DefaultResolver
+	}
+
+	// Server addresses are validated when the config is loaded
+	addr, _ := serverAddr(dnsServer)
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if forceTCP {
+				network = "tcp"
+			}
+			if dnsServer != "" {
+				address = addr
+			}
+			d := net.Dialer{}
+			return d.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// serverAddr turns a configured DNS server into host:port, appending the default
+// port 53 when none is given. IPv6 literals may be bare or in brackets.
+func serverAddr(server string) (string, error) {
+	return serverAddrPort(server, "53")
+}
+
+// serverAddrPort is serverAddr with a different default port
+func serverAddrPort(server, defaultPort string) (string, error) {
+	if host, port, err := net.SplitHostPort(server); err == nil {
+		if host == "" {
+			return "", fmt.Errorf("DNS server %q has no host", server)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("DNS server %q has an invalid port", server)
+		}
+		return net.JoinHostPort(host, port), nil
+	}
+
+	host := server
+	if strings.HasPrefix(host, "[") || strings.HasSuffix(host, "]") {
+		if !strings.HasPrefix(host, "[") || !strings.HasSuffix(host, "]") {
+			return "", fmt.Errorf("DNS server %q has unbalanced brackets", server)
+		}
+		host = host[1 : len(host)-1]
+	}
+	// Anything with a colon left over must be a bare IPv6 address
+	if host == "" || strings.ContainsAny(host, "[]/ ") || (strings.Contains(host, ":") && net.ParseIP(host) == nil) {
+		return "", fmt.Errorf("DNS server %q is not a valid host or host:port", server)
+	}
+	return net.JoinHostPort(host, defaultPort), nil
+}
+
+// retryBackoff is the delay before the first retry; it grows linearly per attempt
+const retryBackoff = 250 * time.Millisecond
+
+var errUnsupportedType = errors.New("unsupported record type")
+
+// PerformDNSCheck runs a check against one server, returning a result without
+// the timestamp, server and duration, which the caller fills in
+func PerformDNSCheck(check *DNSCheck, resolver Resolver, server string) CheckResult {
+	return PerformDNSCheckContext(context.Background(), check, resolver, server)
+}
+
+// PerformDNSCheckContext is PerformDNSCheck with a context that cancels the
+// lookup, the DNSSEC and TTL queries that follow it and any retries still to
+// come. Retries also stop once the check has
+// been removed.
+func PerformDNSCheckContext(ctx context.Context, check *DNSCheck, resolver Resolver, server string) CheckResult {
+	if check.Type == "PTR" && net.ParseIP(check.Domain) == nil {
+		return CheckResult{Status: "UNSUPPORTED", Detail: "PTR checks need an IP address as the domain"}
+	}
+
+	var records, matchRecords []string
+	var note string
+	var err error
+	attempts := 0
+	for {
+		attempts++
+		// Each attempt gets the check's deadline so a hung resolver cannot stall the check
+		attemptCtx, cancel := context.WithTimeout(ctx, check.Timeout)
+		records, matchRecords, note, err = lookupRecords(attemptCtx, check, resolver, server)
+		cancel()
+		if err == nil || attempts > check.Retries || !retryable(check, err) || check.removed.Load() {
+			break
+		}
+		backoff := time.NewTimer(retryBackoff * time.Duration(attempts))
+		select {
+		case <-ctx.Done():
+			backoff.Stop()
+		case <-backoff.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if errors.Is(err, errUnsupportedType) {
+		return CheckResult{Status: "UNSUPPORTED", Attempts: attempts}
+	}
+	check.sortRecords(records)
+	check.sortRecords(matchRecords)
+
+	// Checks with an expected RCODE are judged on it first. Only a NOERROR
+	// answer with an expected value goes on to be matched as usual.
+	var rcode string
+	var rcodeErr *rcodeError
+	if errors.As(err, &rcodeErr) {
+		rcode, err = rcodeErr.name(), nil
+		if rcode != check.ExpectedRcode {
+			return CheckResult{Status: "FAIL", Detail: rcodeDetail(rcode, check.ExpectedRcode),
+				ActualResult: records, Attempts: attempts, Rcode: rcode}
+		}
+		if rcode != "NOERROR" || (check.Expected == "" && !check.RequireResolutionOnly) {
+			return CheckResult{Status: "PASS", ActualResult: records, Attempts: attempts, Rcode: rcode}
+		}
+	}
+	// A name that does not exist cannot hold the value a negated check rules out
+	var notFound *net.DNSError
+	if check.Negate && errors.As(err, &notFound) && notFound.IsNotFound {
+		return CheckResult{Status: "PASS", Detail: "name does not exist", Attempts: attempts}
+	}
+	if err != nil {
+		result := errorResult(check, err)
+		// Validating resolvers answer SERVFAIL for bogus data, so find out whether that is why
+		var dnsErr *net.DNSError
+		if check.DNSSEC && errors.As(err, &dnsErr) && dnsErr.IsTemporary && !dnsErr.IsTimeout {
+			if state, _ := check.dnssecStatus(ctx, resolver, server); state == "BOGUS" {
+				result.Status = "BOGUS"
+			}
+		}
+		result.Attempts = attempts
+		return result
+	}
+
+	// TTLs are best effort unless the check asserts a TTL range
+	var ttls map[string]uint32
+	var ttlErr error
+	if check.CaptureTTL {
+		if ttls, ttlErr = check.recordTTLs(ctx, resolver); ttlErr != nil && check.TTLRange() == "" {
+			log.Printf("Warning: could not read TTLs for %s from %s: %v", check.ID(), server, ttlErr)
+		}
+	}
+
+	answer := func(status, detail string) CheckResult {
+		return CheckResult{Status: status, Detail: detail, ActualResult: records, Attempts: attempts, TTLs: ttls, Rcode: rcode}
+	}
+
+	if matchRecords == nil {
+		matchRecords = records
+	}
+
+	// Check if expected value is in records, or only that the name resolves
+	matched := false
+	var found string
+	if check.RequireResolutionOnly {
+		matched = len(matchRecords) > 0
+	} else if check.Baseline {
+		// The whole answer is compared against the baseline below
+		matched = true
+	} else if _, cidr, err := net.ParseCIDR(check.Expected); err == nil && check.Type == "CHAIN" {
+		for _, record := range matchRecords {
+			if ip := net.ParseIP(record); ip != nil && cidr.Contains(ip) {
+				matched, found = true, record
+				break
+			}
+		}
+	} else {
+		for _, record := range matchRecords {
+			if check.matches(record) {
+				matched, found = true, record
+				break
+			}
+		}
+	}
+	// Negated checks pass only while no record matches
+	if check.Negate {
+		if matched {
+			return answer("FAIL", found+" is present")
+		}
+	} else if !matched {
+		return answer("FAIL", "")
+	}
+
+	// The number of records must fall in the allowed range regardless of their values
+	if count := len(matchRecords); count < check.MinResults || (check.MaxResults > 0 && count > check.MaxResults) {
+		return answer("FAIL", fmt.Sprintf("got %d records, want %s", count, check.ResultRange()))
+	}
+
+	// Every matched record's TTL must fall in the allowed range as well
+	if check.TTLRange() != "" {
+		if ttlErr != nil {
+			result := errorResult(check, ttlErr)
+			result.ActualResult, result.Attempts, result.TTLs = records, attempts, ttls
+			return result
+		}
+		if detail := check.checkTTLs(matchRecords, ttls); detail != "" {
+			return answer("FAIL", detail)
+		}
+	}
+
+	// Email authentication records must also parse and meet the policy requirements
+	if check.Validate != "" {
+		if err := validateEmailAuth(check, records); err != nil {
+			return answer("FAIL", err.Error())
+		}
+	}
+
+	// A passing answer must also match the golden zone file when one covers this record
+	if check.zoneRecords != nil && !sameRecordSet(records, check.zoneRecords) {
+		return answer("DRIFT", "zone has "+strings.Join(check.zoneRecords, ","))
+	}
+
+	// Baseline checks pass only while the answer matches the captured baseline
+	if baseline, ok := check.baselineRecords(); check.Baseline && ok && !sameRecordSet(records, baseline) {
+		return answer("CHANGED", baselineDiff(records, baseline))
+	}
+
+	// Signed zones must also validate on the server
+	if check.DNSSEC {
+		state, err := check.dnssecStatus(ctx, resolver, server)
+		if err != nil {
+			result := errorResult(check, err)
+			result.ActualResult, result.Attempts = records, attempts
+			return result
+		}
+		if state != "" {
+			return answer(state, "")
+		}
+	}
+
+	return answer("PASS", note)
+}
+
+// lookupRecords performs a single lookup for the check. matchRecords is nil
+// unless only part of the answer should be matched against the expected value.
+func lookupRecords(ctx context.Context, check *DNSCheck, resolver Resolver, server string) (records, matchRecords []string, note string, err error) {
+	if check.clientSubnet != nil || check.ExpectedRcode != "" || check.qclass != 0 {
+		records, err := lookupDirect(ctx, check, resolver, server)
+		return records, nil, "", err
+	}
+
+	switch check.Type {
+	case "A":
+		ips, err := resolver.LookupIP(ctx, "ip4", check.queryName())
+		if err != nil {
+			return nil, nil, "", err
+		}
+		for _, ip := range ips {
+			records = append(records, ip.String())
+		}
+
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, check.queryName())
+		if err != nil {
+			return nil, nil, "", err
+		}
+		records = append(records, cname)
+
+	case "NS":
+		ns, err := resolver.LookupNS(ctx, check.queryName())
+		if err != nil {
+			return nil, nil, "", err
+		}
+		for _, nsRecord := range ns {
+			records = append(records, nsRecord.Host)
+		}
+
+	case "TXT":
+		txtRecords, err := resolver.LookupTXT(ctx, check.queryName())
+		if err != nil {
+			return nil, nil, "", err
+		}
+		records = append(records, txtRecords...)
+
+	case "MX":
+		mxRecords, err := resolver.LookupMX(ctx, check.queryName())
+		if err != nil {
+			return nil, nil, "", err
+		}
+		for _, mx := range mxRecords {
+			records = append(records, mx.Host)
+		}
+
+	case "SRV":
+		// Domain holds the full _service._proto.name query name
+		_, srvRecords, err := resolver.LookupSRV(ctx, "", "", check.queryName())
+		if err != nil {
+			return nil, nil, "", err
+		}
+		for _, srv := range srvRecords {
+			records = append(records, fmt.Sprintf("%d %d %d %s", srv.Priority, srv.Weight, srv.Port, srv.Target))
+		}
+
+	case "SOA":
+		soa, err := resolver.LookupSOA(ctx, check.queryName())
+		if err != nil {
+			return nil, nil, "", err
+		}
+		record, _ := rrValue(soa)
+		records = append(records, record)
+
+		// Flag zone changes even when the answer still matches
+		if previous, ok := check.previousSerial(server); ok && previous != soa.Serial {
+			note = fmt.Sprintf("serial changed from %d to %d", previous, soa.Serial)
+		}
+
+	case "CAA":
+		caaRecords, err := resolver.LookupCAA(ctx, check.queryName())
+		if err != nil {
+			return nil, nil, "", err
+		}
+		for _, caa := range caaRecords {
+			record, _ := rrValue(caa)
+			records = append(records, record)
+		}
+
+	case "PTR":
+		names, err := resolver.LookupAddr(ctx, check.queryName())
+		if err != nil {
+			return nil, nil, "", err
+		}
+		records = append(records, names...)
+
+	case "EXEC":
+		records, err = runCommand(ctx, check, server)
+		if err != nil {
+			return nil, nil, "", err
+		}
+
+	case "CHAIN":
+		hops, terminal, err := resolveChain(ctx, resolver, server, check.queryName())
+		if err != nil {
+			return nil, nil, "", err
+		}
+		// Each hop is recorded for debugging, but only the terminal addresses are matched
+		records = hops
+		matchRecords = terminal
+
+	default:
+		return nil, nil, "", errUnsupportedType
+	}
+	return records, matchRecords, note, nil
+}
+
+// retryable reports whether a failed lookup may succeed on another attempt.
+// Definitive answers such as NXDOMAIN are not retried, unless the error
+// matches one of the check's transient patterns.
+func retryable(check *DNSCheck, err error) bool {
+	var rcodeErr *rcodeError
+	if check.ExpectedRcode != "" && errors.As(err, &rcodeErr) {
+		// The response code is the answer being checked
+		return false
+	}
+	if check.transientError(err) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound && (dnsErr.IsTimeout || dnsErr.IsTemporary)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// matches reports whether a single record satisfies the expected value under
+// the check's match mode
+func (check *DNSCheck) matches(record string) bool {
+	switch check.MatchMode {
+	case "exact":
+		if check.Type == "TXT" {
+			// TXT records are compared whole, with their strings already joined
+			return strings.EqualFold(record, joinTXTStrings(check.Expected))
+		}
+		// Trailing dots on host names are not significant
+		return normalizeRecord(record) == normalizeRecord(check.Expected)
+	case "regex":
+		// Host names may be matched with or without their trailing dot
+		return check.expectedRegexp.MatchString(record) || check.expectedRegexp.MatchString(strings.TrimSuffix(record, "."))
+	default:
+		return strings.Contains(strings.ToLower(record), strings.ToLower(check.Expected))
+	}
+}
+
+// joinTXTStrings concatenates an expected TXT value written in zone file style
+// as quoted strings (`"v=DKIM1; k=rsa; " "p=MIIB..."`), the way resolvers join
+// a long record's strings. Values that are not entirely quoted strings are
+// returned unchanged.
+func joinTXTStrings(expected string) string {
+	rest := strings.TrimSpace(expected)
+	if !strings.HasPrefix(rest, `"`) {
+		return expected
+	}
+
+	var joined strings.Builder
+	for rest != "" {
+		if rest[0] != '"' {
+			return expected
+		}
+		end := -1
+		for i := 1; i < len(rest); i++ {
+			if rest[i] == '\\' && i+1 < len(rest) {
+				i++
+				joined.WriteByte(rest[i])
+				continue
+			}
+			if rest[i] == '"' {
+				end = i
+				break
+			}
+			joined.WriteByte(rest[i])
+		}
+		if end < 0 {
+			return expected
+		}
+		rest = strings.TrimLeft(rest[end+1:], " \t")
+	}
+	return joined.String()
+}
+
+// Disabled reports whether the check is switched off with enabled: false
+func (check *DNSCheck) Disabled() bool {
+	return check.Enabled != nil && !*check.Enabled
+}
+
+// ResultRange describes the allowed number of records, or "" when unrestricted
+func (check *DNSCheck) ResultRange() string {
+	switch {
+	case check.MinResults > 0 && check.MaxResults > 0:
+		return fmt.Sprintf("%d-%d", check.MinResults, check.MaxResults)
+	case check.MinResults > 0:
+		return fmt.Sprintf("at least %d", check.MinResults)
+	case check.MaxResults > 0:
+		return fmt.Sprintf("at most %d", check.MaxResults)
+	}
+	return ""
+}
+
+// errorResult builds the result for a failed lookup, classifying errors that match
+// one of the check's transient patterns as TRANSIENT, TLS certificate failures
+// as CERT, names that do not exist as NXDOMAIN and timeouts as TIMEOUT instead
+// of ERROR
+func errorResult(check *DNSCheck, err error) CheckResult {
+	if check.transientError(err) {
+		return CheckResult{Status: "TRANSIENT", Error: err.Error()}
+	}
+	if certError(err) {
+		return CheckResult{Status: "CERT", Error: err.Error()}
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return CheckResult{Status: "NXDOMAIN", Error: err.Error()}
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return CheckResult{Status: "TIMEOUT", Error: err.Error()}
+	}
+	return CheckResult{Status: "ERROR", Error: err.Error()}
+}
+
+// transientError reports whether an error matches one of the check's
+// transient patterns, ignoring case
+func (check *DNSCheck) transientError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, pattern := range check.TransientErrors {
+		if pattern != "" && strings.Contains(msg, strings.ToLower(pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Monitor schedules every check on a pool of max_concurrent_checks workers
+// and waits for them to stop once ctx is cancelled. Call Setup first.
+func (c *Config) Monitor(ctx context.Context) {
+	c.ctx = ctx
+
+	// A reload before this point only swaps c.Checks; one after it finds
+	// every check already scheduled
+	scheduler := newScheduler()
+	c.mu.Lock()
+	c.scheduler = scheduler
+	for _, check := range c.Checks {
+		c.startMonitor(check)
+	}
+	c.mu.Unlock()
+
+	c.monitors.Add(1 + c.Global.MaxConcurrent)
+	go func() {
+		defer c.monitors.Done()
+		scheduler.run(ctx)
+	}()
+	for i := 0; i < c.Global.MaxConcurrent; i++ {
+		go func() {
+			defer c.monitors.Done()
+			c.worker(scheduler)
+		}()
+	}
+
+	if c.Global.Discover != nil {
+		c.monitors.Add(1)
+		go func() {
+			defer c.monitors.Done()
+			c.runDiscovery()
+		}()
+	}
+
+	c.monitors.Add(1)
+	go func() {
+		defer c.monitors.Done()
+		c.watchStale(ctx)
+	}()
+
+	c.monitoring.Store(true)
+	defer c.monitoring.Store(false)
+	c.monitors.Wait()
+}
+
+// startMonitor schedules a check's polls; stopMonitor unschedules a check
+// that is being dropped and marks it removed. The first poll is offset so
+// checks sharing an interval don't all hit the resolvers at once, and later
+// polls stay spread out. Disabled checks keep their history but are not
+// polled. Both are called with c.mu held, and before Monitor has created the
+// scheduler they leave scheduling to it.
+func (c *Config) startMonitor(check *DNSCheck) {
+	if check.Disabled() || c.scheduler == nil {
+		return
+	}
+	c.scheduler.add(check, time.Now().Add(c.startDelay(check)))
+}
+
+// stopMonitor unschedules a check. An in-flight poll always completes so its
+// result is recorded.
+func (c *Config) stopMonitor(check *DNSCheck) {
+	check.removed.Store(true)
+	if c.scheduler != nil {
+		c.scheduler.remove(check)
+	}
+	forgetPrometheus(check)
+}
+
+// startDelay picks a random delay for a check's first poll, up to the global
+// jitter but always shorter than the check's interval
+func (c *Config) startDelay(check *DNSCheck) time.Duration {
+	jitter := min(*c.Global.Jitter, check.Interval)
+	if jitter <= 0 {
+		return 0
+	}
+	return rand.N(jitter)
+}
+
+// pollServers queries every configured server for a check in parallel and
+// records the results in server order, followed by a MISMATCH result when the
+// servers disagree
+func (c *Config) pollServers(check *DNSCheck) {
+	results := c.queryServers(c.ctx, check)
+	if c.ctx.Err() != nil {
+		// Cut short by shutdown, so the results say nothing about the servers
+		return
+	}
+
+	// The first answer becomes the baseline when none has been captured yet
+	if _, ok := check.baselineRecords(); check.Baseline && !ok {
+		for _, result := range results {
+			if statusClass(result.Status) != "PASS" {
+				continue
+			}
+			if err := c.captureBaseline(check, result.ActualResult); err != nil {
+				log.Printf("Error saving baseline for %s: %v", check.ID(), err)
+			}
+			break
+		}
+	}
+
+	for _, result := range results {
+		c.updateStatus(check, result)
+	}
+
+	// Propagation checks report how far the expected value has spread instead
+	if check.Propagation {
+		check.historyLock.RLock()
+		propagation, ok := propagationResult(check, results)
+		check.historyLock.RUnlock()
+		if ok {
+			c.updateStatus(check, propagation)
+		}
+		return
+	}
+
+	// Servers that disagree usually mean a change is still propagating
+	if mismatch, ok := compareServers(check, results); ok {
+		c.updateStatus(check, mismatch)
+	}
+}
+
+// queryServers runs a check against every configured server in parallel and
+// returns the results in server order
+func (c *Config) queryServers(ctx context.Context, check *DNSCheck) []CheckResult {
+	results := make([]CheckResult, len(c.servers))
+
+	var wg sync.WaitGroup
+	for i, server := range c.servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			resolver := c.resolvers[i]
+			if check.TCP {
+				resolver = c.tcpResolvers[i]
+			}
+			// Each result is stamped with when its own server was queried
+			start := time.Now()
+			result := PerformDNSCheckContext(ctx, check, resolver, server)
+			result.Timestamp = start
+			result.Server = server // we still use the server name from config
+			result.ClientSubnet = check.ClientSubnet
+			result.Duration = time.Since(start)
+			results[i] = result
+		}(i, server)
+	}
+	wg.Wait()
+	return results
+}
+
+// compareServers returns a MISMATCH result when servers that returned an answer
+// disagree on the record set, describing what each of them returned
+func compareServers(check *DNSCheck, results []CheckResult) (CheckResult, bool) {
+	var answered []CheckResult
+	for _, result := range results {
+		switch statusClass(result.Status) {
+		case "PASS", "FAIL", "DRIFT", "CHANGED", "NXDOMAIN", "BOGUS", "INSECURE":
+			answered = append(answered, result)
+		}
+	}
+	if len(answered) < 2 {
+		return CheckResult{}, false
+	}
+
+	agree := true
+	for _, result := range answered[1:] {
+		if !sameRecordSet(result.ActualResult, answered[0].ActualResult) {
+			agree = false
+			break
+		}
+	}
+	if agree {
+		return CheckResult{}, false
+	}
+
+	var answers, servers []string
+	for _, result := range answered {
+		answers = append(answers, fmt.Sprintf("%s returned %s", result.Server, strings.Join(result.ActualResult, ",")))
+		servers = append(servers, result.Server)
+	}
+	return CheckResult{
+		Status:    "MISMATCH",
+		Detail:    strings.Join(answers, "; "),
+		Timestamp: answered[0].Timestamp,
+		Server:    strings.Join(servers, " vs "),
+	}, true
+}
+
+const statusPageHTML = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>DNS Monitor Status</title>
+    <script>
+        // ?theme=dark or ?theme=light overrides and remembers the choice
+        (function() {
+            var theme = new URLSearchParams(location.search).get("theme");
+            if (theme === "dark" || theme === "light") {
+                localStorage.setItem("theme", theme);
+            }
+            document.documentElement.setAttribute("data-theme", localStorage.getItem("theme") || "light");
+        })();
+        function toggleTheme() {
+            var theme = document.documentElement.getAttribute("data-theme") === "dark" ? "light" : "dark";
+            document.documentElement.setAttribute("data-theme", theme);
+            localStorage.setItem("theme", theme);
+        }
+    </script>
+    <style>
+        :root {
+            --page-bg: #fff; --page-fg: #222; --muted: #666; --link: #337ab7; --detail-bg: rgba(255,255,255,0.5);
+            --pass-bg: #dff0d8; --pass-fg: #3c763d;
+            --fail-bg: #f2dede; --fail-fg: #a94442;
+            --error-bg: #fcf8e3; --error-fg: #8a6d3b;
+            --drift-bg: #f3e5f5; --drift-fg: #6a1b9a;
+            --changed-bg: #e8eaf6; --changed-fg: #283593;
+            --mismatch-bg: #fff3e0; --mismatch-fg: #e65100;
+            --nxdomain-bg: #ffcdd2; --nxdomain-fg: #b71c1c;
+            --disabled-bg: #f5f5f5; --disabled-fg: #9e9e9e;
+            --insecure-bg: #fff8e1; --insecure-fg: #795548;
+            --cert-bg: #fce4ec; --cert-fg: #880e4f;
+            --transient-bg: #d9edf7; --transient-fg: #31708f;
+            --timeout-bg: #fbe9e7; --timeout-fg: #bf360c;
+            --pending-bg: #f5f5f5; --pending-fg: #777;
+        }
+        [data-theme="dark"] {
+            --page-bg: #121417; --page-fg: #ddd; --muted: #9aa0a6; --link: #8ab4f8; --detail-bg: rgba(0,0,0,0.25);
+            --pass-bg: #1e3320; --pass-fg: #81c784;
+            --fail-bg: #3b1d1d; --fail-fg: #ef9a9a;
+            --error-bg: #3a3219; --error-fg: #e6c36a;
+            --drift-bg: #2f1f36; --drift-fg: #ce93d8;
+            --changed-bg: #1f2340; --changed-fg: #9fa8da;
+            --mismatch-bg: #3a2714; --mismatch-fg: #ffb74d;
+            --nxdomain-bg: #4a1616; --nxdomain-fg: #ff8a80;
+            --disabled-bg: #222; --disabled-fg: #888;
+            --insecure-bg: #33291a; --insecure-fg: #d7b899;
+            --cert-bg: #3a1a2a; --cert-fg: #f48fb1;
+            --transient-bg: #17303d; --transient-fg: #81d4fa;
+            --timeout-bg: #3d2117; --timeout-fg: #ffab91;
+            --pending-bg: #222; --pending-fg: #aaa;
+        }
+        body { font-family: Arial, sans-serif; margin: 20px; background-color: var(--page-bg); color: var(--page-fg); }
+        a { color: var(--link); }
+        .theme-toggle { float: right; cursor: pointer; background: none; color: var(--page-fg); border: 1px solid var(--muted); border-radius: 4px; padding: 4px 10px; }
+        .status { margin: 20px 0; padding: 15px; border-radius: 4px; box-shadow: 0 1px 3px rgba(0,0,0,0.1); }
+        .PASS { background-color: var(--pass-bg); color: var(--pass-fg); border-left: 5px solid var(--pass-fg); }
+        .FAIL { background-color: var(--fail-bg); color: var(--fail-fg); border-left: 5px solid var(--fail-fg); }
+        .ERROR { background-color: var(--error-bg); color: var(--error-fg); border-left: 5px solid var(--error-fg); }
+        .DRIFT { background-color: var(--drift-bg); color: var(--drift-fg); border-left: 5px solid var(--drift-fg); }
+        .CHANGED { background-color: var(--changed-bg); color: var(--changed-fg); border-left: 5px solid var(--changed-fg); }
+        .MISMATCH { background-color: var(--mismatch-bg); color: var(--mismatch-fg); border-left: 5px solid var(--mismatch-fg); font-weight: bold; }
+        .PROPAGATING { background-color: var(--mismatch-bg); color: var(--mismatch-fg); border-left: 5px solid var(--mismatch-fg); }
+        .PROPAGATED { background-color: var(--pass-bg); color: var(--pass-fg); border-left: 5px solid var(--pass-fg); }
+        .NXDOMAIN { background-color: var(--nxdomain-bg); color: var(--nxdomain-fg); border-left: 5px solid var(--nxdomain-fg); font-weight: bold; }
+        .DISABLED { background-color: var(--disabled-bg); color: var(--disabled-fg); border-left: 5px solid var(--disabled-fg); }
+        .BOGUS { background-color: var(--nxdomain-bg); color: var(--nxdomain-fg); border-left: 5px solid var(--nxdomain-fg); }
+        .INSECURE { background-color: var(--insecure-bg); color: var(--insecure-fg); border-left: 5px solid var(--insecure-fg); }
+        .CERT { background-color: var(--cert-bg); color: var(--cert-fg); border-left: 5px solid var(--cert-fg); }
+        .error-text { font-family: monospace; }
+        .mismatch-banner { background-color: var(--mismatch-fg); color: var(--page-bg); padding: 10px 15px; border-radius: 4px; }
+        .TRANSIENT { background-color: var(--transient-bg); color: var(--transient-fg); border-left: 5px solid var(--transient-fg); }
+        .TIMEOUT { background-color: var(--timeout-bg); color: var(--timeout-fg); border-left: 5px solid var(--timeout-fg); }
+        .PENDING { background-color: var(--pending-bg); color: var(--pending-fg); border-left: 5px solid var(--pending-fg); }
+        .STALE { background-color: var(--timeout-bg); color: var(--timeout-fg); border-left: 5px dashed var(--timeout-fg); font-weight: bold; }
+        .details { font-size: 0.9em; color: var(--muted); margin: 5px 0; }
+        .current-status { margin-top: 10px; font-size: 0.9em; }
+        .result-detail { font-family: monospace; margin: 5px 0 5px 20px; padding: 5px; background: var(--detail-bg); }
+        .check-header { font-size: 1.1em; font-weight: bold; margin-bottom: 10px; }
+        .description { white-space: pre-line; margin: -5px 0 10px; }
+        .added { color: var(--pass-fg); font-weight: bold; }
+        .diagnostics { margin: 20px 0; font-size: 0.9em; }
+        .diagnostics .error { color: var(--fail-fg); }
+        .diagnostics .warning { color: var(--error-fg); }
+        .annotation { font-size: 0.9em; color: var(--transient-fg); margin: 5px 0 5px 20px; }
+        .timeline { margin-top: 10px; line-height: 0; }
+        .timeline .tick { display: inline-block; width: 0; height: 18px; margin-right: 1px; }
+        .timeline .tick.annotated { height: 26px; }
+        .removed { color: var(--fail-fg); font-weight: bold; text-decoration: line-through; }
+        .label { display: inline-block; font-size: 0.75em; font-weight: normal; margin-left: 6px; padding: 1px 6px; border-radius: 8px; border: 1px solid currentColor; color: inherit; text-decoration: none; }
+        .summary { margin: 20px 0; padding: 10px 15px; border-radius: 4px; font-size: 1.1em; }
+        .maintenance { font-weight: normal; font-style: italic; }
+        .group { margin-top: 30px; border-bottom: 1px solid var(--muted); }
+        .card-rows { width: 100%; border-collapse: collapse; font-size: 0.9em; }
+        .card-rows td { padding: 6px 8px; vertical-align: top; }
+        .card-type { font-weight: bold; white-space: nowrap; }
+    </style>
+</head>
+<body>
+    <button class="theme-toggle" type="button" onclick="toggleTheme()">Toggle theme</button>
+    <h1>DNS Monitor Status</h1>
+    <p>
+        DNS Servers: {{range $i, $s := .Global.DNSServers}}{{if $i}}, {{end}}{{$s}}{{else}}system resolver{{end}}
+        {{with .Role}}
+        <br>Instance Role: {{.}}
+        {{end}}
+    </p>
+    {{with .Summary}}
+    <div class="summary {{.Worst}}">
+        <strong>{{.Passing}}</strong> passing &middot;
+        <strong>{{.Failing}}</strong> failing &middot;
+        <strong>{{.Errors}}</strong> errors &middot;
+        <strong>{{.Pending}}</strong> pending{{if .Disabled}} &middot;
+        <strong>{{.Disabled}}</strong> disabled{{end}}
+    </div>
+    {{end}}
+    {{with .Mismatched}}
+    <div class="mismatch-banner">
+        DNS servers disagree for {{len .}} check(s): {{range $i, $c := .}}{{if $i}}, {{end}}{{$c.Domain}} ({{$c.Type}}){{end}}
+    </div>
+    {{end}}
+    {{with .Diagnostics}}
+    <details class="diagnostics">
+        <summary>Diagnostics ({{len .}} recent errors/warnings)</summary>
+        {{range .}}
+        <div class="result-detail {{.Level}}">{{.Time.Format "2006-01-02 15:04:05"}} [{{.Level}}] {{.Message}}</div>
+        {{end}}
+    </details>
+    {{end}}
+    {{if .Filter}}
+    <p class="filter">
+        Showing checks labelled {{range $i, $l := .Filter}}{{if $i}} and {{end}}<span class="label">{{$l}}</span>{{end}}
+        &middot; <a href="/">show all</a>
+    </p>
+    {{end}}
+    <p class="view">
+        {{if .ByDomain}}<a href="{{.ViewLink false}}">One box per check</a>{{else}}<a href="{{.ViewLink true}}">One card per domain</a>{{end}}
+    </p>
+    {{range .Groups}}
+    {{with .Name}}<h2 class="group">{{.}}</h2>{{end}}
+    {{if $.ByDomain}}
+    {{range .Domains}}
+    <div class="status {{.Status}}">
+        <div class="check-header">{{.Domain}} <small>({{len .Checks}} checks)</small></div>
+        <table class="card-rows">
+            {{range .Checks}}
+            <tr class="{{statusClass .Status}}">
+                <td class="card-type" title="{{.Description}}">{{.Type}}{{with .Class}} {{.}}{{end}}{{with .Name}}<br><small>{{.}}</small>{{end}}</td>
+                <td>{{.Status}}{{if .Disabled}} <small>(disabled)</small>{{end}}{{with $.Maintenance .}} <small class="maintenance">(in maintenance)</small>{{end}}</td>
+                <td>
+                    Expected: {{if .RequireResolutionOnly}}any answer{{else}}{{if .Negate}}not {{end}}{{.Expected}}{{end}}
+                    {{if .HasResults}}{{range ($.ServerResults .)}}<br>{{with .Server}}{{.}}{{else}}system resolver{{end}}: {{with .Error}}<span class="error-text">{{.}}</span>{{else}}{{range $i, $r := .ActualResult}}{{if $i}}, {{end}}{{$r}}{{else}}no records{{end}}{{end}}{{end}}{{else}}<br>No checks performed yet{{end}}
+                </td>
+                <td>{{if not .LastCheck.IsZero}}{{.LastCheck.Format "2006-01-02 15:04:05"}}{{end}}</td>
+            </tr>
+            {{end}}
+        </table>
+    </div>
+    {{end}}
+    {{else}}
+    {{range .Checks}}
+    <div class="status {{statusClass .Status}}">
+        <div class="check-header">
+            {{if .Name}}{{.Name}}: {{end}}{{.Domain}} ({{.Type}}){{if .Discovered}} <small>(discovered)</small>{{end}}{{if .Runtime}} <small>(added via API)</small>{{end}}{{if .Disabled}} <small>(disabled)</small>{{end}}
+            {{with $.Maintenance .}}<small class="maintenance">(in maintenance until {{.End.Format "2006-01-02 15:04"}}{{with .Reason}}: {{.}}{{end}})</small>{{end}}
+            {{range .Labels.Sorted}}<a class="label" href="/?label={{.}}">{{.}}</a>{{end}}
+        </div>
+        {{if or .Description .Runbook}}<div class="description">{{.Description}}{{with .Runbook}}{{if $.Description}} {{end}}<a href="{{.}}">Runbook</a>{{end}}</div>{{end}}
+        <div class="details">
+            {{if .Name}}<a href="/api/trace?name={{.Name}}">{{else}}<a href="/api/trace?domain={{.Domain}}&type={{.Type}}">{{end}}Resolution trace</a><br>
+            Expected: {{if .RequireResolutionOnly}}any answer (resolution only){{else}}{{if .Negate}}not {{end}}{{.Expected}}{{if and .MatchMode (ne .MatchMode "contains")}} ({{.MatchMode}} match){{end}}{{end}}<br>
+            {{if eq .Status "STALE"}}<strong>Stale:</strong> no result for over {{.StaleAfter}}; polling may be stuck<br>{{end}}
+            Check Interval: {{.Interval}}
+            {{if not .LastCheck.IsZero}}<br>Last Check: {{.LastCheck.Format "2006-01-02 15:04:05"}}{{end}}
+            {{$next := $.NextRun .}}{{if not $next.IsZero}}<br>Next Check: {{if $.Due .}}due since {{$next.Format "2006-01-02 15:04:05"}} (polling or waiting for a worker){{else}}{{$next.Format "2006-01-02 15:04:05"}}{{end}}{{end}}
+            {{with .ResultRange}}<br>Allowed Record Count: {{.}}{{end}}
+            {{with .TTLRange}}<br>Allowed TTL: {{.}}{{end}}
+            {{with .ClientSubnet}}<br>Client Subnet: {{.}}{{end}}
+            {{with .ExpectedRcode}}<br>Expected RCODE: {{.}}{{end}}
+            {{with .Class}}<br>Class: {{.}}{{end}}
+            <br>Uptime: {{range $i, $u := $.Uptime .}}{{if $i}} &middot; {{end}}{{$u.Window}} {{$u}}{{end}}
+            {{if .SLO}}<br>SLO: {{.SLO.Target}}% over {{.SLO.Window}}, burn rate {{printf "%.1f" .BurnRate}}x (alert above {{.SLO.BurnRate}}x){{end}}
+        </div>
+        <div class="current-status">
+            <strong>Current Status:</strong>
+            {{if .HasResults}}
+            {{range ($.ServerResults .)}}
+            <div class="result-detail {{statusClass .Status}}">
+                Time: {{.Timestamp.Format "2006-01-02 15:04:05"}}<br>
+                Status: {{.Status}}<br>
+                {{with .LastSeen}}Last seen: {{.Format "2006-01-02 15:04:05"}}<br>{{end}}
+                {{if gt .Count 1}}Unchanged for {{.Count}} polls<br>{{end}}
+                {{with .Error}}<span class="error-text">Error: {{.}}</span><br>{{end}}
+                {{with .Detail}}Detail: {{.}}<br>{{end}}
+                Server: {{.Server}}
+                {{with .Rcode}}<br>RCODE: {{.}}{{end}}
+                {{if gt .Attempts 1}}<br>Attempts: {{.Attempts}}{{end}}
+                {{if .ActualResult}}
+                {{$ttls := .TTLs}}
+                <br>Results ({{len .ActualResult}}): {{range .ActualResult}}{{.}}{{with index $ttls .}} <small>(TTL {{.}}s)</small>{{end}} {{end}}
+                {{end}}
+            </div>
+            {{end}}
+            {{with .Diff}}
+            <div class="result-detail">
+                <strong>Changed since last poll:</strong>
+                {{if .StatusChanged}}<br>Status: {{.PreviousStatus}} &rarr; {{.CurrentStatus}}{{end}}
+                {{if .Added}}<br>Added: {{range .Added}}<span class="added">+{{.}}</span> {{end}}{{end}}
+                {{if .Removed}}<br>Removed: {{range .Removed}}<span class="removed">-{{.}}</span> {{end}}{{end}}
+            </div>
+            {{end}}
+            {{else}}
+            <div class="result-detail">No checks performed yet</div>
+            {{end}}
+        </div>
+        {{with $.Timeline .}}
+        <div class="timeline" title="Last {{len .}} polls, oldest first">
+            {{range .}}<span class="tick {{.Class}}{{if .Annotations}} annotated{{end}}" title="{{.Timestamp.Format "2006-01-02 15:04:05"}}{{range .Statuses}}&#10;{{.}}{{end}}{{range .Annotations}}&#10;Note: {{.Note}}{{end}}"></span>{{end}}
+        </div>
+        {{end}}
+        {{with .AnnotationsSnapshot}}
+        <div class="current-status">
+            <strong>Annotations:</strong>
+            {{range .}}
+            <div class="annotation">{{.Timestamp.Format "2006-01-02 15:04:05"}} &mdash; {{.Note}}</div>
+            {{end}}
+        </div>
+        {{end}}
+    </div>
+    {{end}}
+    {{end}}
+    {{end}}
+</body>
+</html>
+`
+
+// Helper function to get the most recent check
+func lastCheck(history []CheckResult) *CheckResult {
+	if len(history) == 0 {
+		return nil
+	}
+	return &history[len(history)-1]
+}
+
+// ResultDiff describes what changed between two consecutive polls of the same server
+type ResultDiff struct {
+	PreviousStatus string
+	CurrentStatus  string
+	StatusChanged  bool
+	Added          []string
+	Removed        []string
+}
+
+// resultDiff compares the most recent result with the previous result from the
+// same server, returning nil when there is nothing to compare or nothing changed
+func resultDiff(history []CheckResult) *ResultDiff {
+	if len(history) < 2 {
+		return nil
+	}
+	current := history[len(history)-1]
+
+	var previous *CheckResult
+	for i := len(history) - 2; i >= 0; i-- {
+		if history[i].Server == current.Server {
+			previous = &history[i]
+			break
+		}
+	}
+	if previous == nil {
+		return nil
+	}
+
+	diff := &ResultDiff{
+		PreviousStatus: statusClass(previous.Status),
+		CurrentStatus:  statusClass(current.Status),
+	}
+	diff.StatusChanged = diff.PreviousStatus != diff.CurrentStatus
+
+	// Records are compared normalized so reordering or a change in case is not reported
+	before := make(map[string]bool)
+	for _, record := range previous.ActualResult {
+		before[normalizeRecord(record)] = true
+	}
+	after := make(map[string]bool)
+	for _, record := range current.ActualResult {
+		after[normalizeRecord(record)] = true
+		if !before[normalizeRecord(record)] {
+			diff.Added = append(diff.Added, record)
+		}
+	}
+	for _, record := range previous.ActualResult {
+		if !after[normalizeRecord(record)] {
+			diff.Removed = append(diff.Removed, record)
+		}
+	}
+
+	if !diff.StatusChanged && len(diff.Added) == 0 && len(diff.Removed) == 0 {
+		return nil
+	}
+	return diff
+}
+
+func contains(s, substr string) bool {
+	return strings.Contains(s, substr)
+}
+
+// statusKinds are the statuses a result can have, each with a CSS class on the
+// status page. UNSUPPORTED results show as PENDING.
+var statusKinds = []string{"PASS", "FAIL", "ERROR", "TIMEOUT", "TRANSIENT", "NXDOMAIN", "CERT",
+	"DRIFT", "CHANGED", "MISMATCH", "BOGUS", "INSECURE", "DISABLED", "PROPAGATING", "PROPAGATED", "STALE"}
+
+// statusClass maps a status to the CSS class used on the status page
+func statusClass(status string) string {
+	if slices.Contains(statusKinds, status) {
+		return status
+	}
+	return "PENDING"
+}
@@ -0,0 +1,1333 @@
+package dnsmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func testResolver() *mockResolver {
+	return &mockResolver{
+		ips: map[string][]net.IP{
+			"example.com":           {net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")},
+			"lookalike.com":         {net.ParseIP("11.2.3.45")},
+			"cdn.example.net":       {net.ParseIP("203.0.113.7"), net.ParseIP("2001:db8::7")},
+			"web.example.org":       {net.ParseIP("198.51.100.4")},
+			"rotated.example.com":   {net.ParseIP("192.0.2.20"), net.ParseIP("192.0.2.3")},
+			"xn--bcher-kva.example": {net.ParseIP("192.0.2.9")},
+		},
+		cname: map[string]string{
+			"www.example.com":   "cdn.example.net.",
+			"alias.example.com": "www.example.com.",
+		},
+		dname: map[string]string{
+			"old.example": "example.org.",
+		},
+		ns: map[string][]*net.NS{
+			"example.com": {{Host: "ns1.example.com."}, {Host: "ns2.example.com."}},
+		},
+		txt: map[string][]string{
+			"example.com":       {"v=spf1 include:_spf.example.net ~all"},
+			"empty.example.com": {},
+		},
+		mx: map[string][]*net.MX{
+			"example.com": {{Host: "mail.example.com.", Pref: 10}},
+		},
+		srv: map[string][]*net.SRV{
+			"_sip._tcp.example.com": {{Target: "sip.example.com.", Port: 5060, Priority: 10, Weight: 60}},
+		},
+		ptr: map[string][]string{
+			"192.0.2.25": {"mail.example.com."},
+		},
+		caa: map[string][]*dns.CAA{
+			"example.com": {
+				{Flag: 0, Tag: "issue", Value: "letsencrypt.org"},
+				{Flag: 128, Tag: "iodef", Value: "mailto:security@example.com"},
+			},
+			"nocaa.example.com": nil,
+		},
+		secure: map[string]bool{"example.com": true},
+		ttl:    map[string]uint32{"example.com": 60},
+		rcode:  map[string]int{"refused.example.com": dns.RcodeRefused},
+		bogus:  map[string]bool{"bogus.example.com": true},
+		soa: map[string]*dns.SOA{
+			"example.com": {
+				Ns: "ns1.example.com.", Mbox: "admin.example.com.", Serial: 2024010101,
+				Refresh: 3600, Retry: 600, Expire: 86400, Minttl: 300,
+			},
+		},
+	}
+}
+
+func TestPerformDNSCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		check      *DNSCheck
+		err        error
+		wantStatus string
+		wantError  string
+		wantDetail string
+		wantRecs   []string
+	}{
+		{
+			name:       "A pass",
+			check:      &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.2"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"192.0.2.1", "192.0.2.2"},
+		},
+		{
+			name:       "A fail",
+			check:      &DNSCheck{Domain: "example.com", Type: "A", Expected: "198.51.100.1"},
+			wantStatus: "FAIL",
+			wantRecs:   []string{"192.0.2.1", "192.0.2.2"},
+		},
+		{
+			name:       "A substring matches in contains mode",
+			check:      &DNSCheck{Domain: "lookalike.com", Type: "A", Expected: "1.2.3.4"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"11.2.3.45"},
+		},
+		{
+			name:       "A substring does not match in exact mode",
+			check:      &DNSCheck{Domain: "lookalike.com", Type: "A", Expected: "1.2.3.4", MatchMode: "exact"},
+			wantStatus: "FAIL",
+			wantRecs:   []string{"11.2.3.45"},
+		},
+		{
+			name:       "A regex",
+			check:      &DNSCheck{Domain: "example.com", Type: "A", Expected: `^192\.0\.2\.\d+$`, MatchMode: "regex"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"192.0.2.1", "192.0.2.2"},
+		},
+		{
+			name:       "A NXDOMAIN",
+			check:      &DNSCheck{Domain: "missing.example.com", Type: "A", Expected: "192.0.2.1"},
+			wantStatus: "NXDOMAIN",
+			wantError:  "lookup missing.example.com on mock: no such host",
+		},
+		{
+			name:       "A server failure",
+			check:      &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.1"},
+			err:        &net.DNSError{Err: "server misbehaving", Name: "example.com", Server: "mock"},
+			wantStatus: "ERROR",
+			wantError:  "lookup example.com on mock: server misbehaving",
+		},
+		{
+			name:       "A timeout",
+			check:      &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.1"},
+			err:        &net.DNSError{Err: "i/o timeout", Name: "example.com", Server: "mock", IsTimeout: true},
+			wantStatus: "TIMEOUT",
+			wantError:  "lookup example.com on mock: i/o timeout",
+		},
+		{
+			name:       "CNAME is case-insensitive",
+			check:      &DNSCheck{Domain: "www.example.com", Type: "CNAME", Expected: "CDN.Example.NET"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"cdn.example.net."},
+		},
+		{
+			name:       "CNAME exact ignores trailing dot and case",
+			check:      &DNSCheck{Domain: "www.example.com", Type: "CNAME", Expected: "CDN.example.net", MatchMode: "exact"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"cdn.example.net."},
+		},
+		{
+			name:       "CNAME regex without the trailing dot",
+			check:      &DNSCheck{Domain: "www.example.com", Type: "CNAME", Expected: `^cdn\.example\.net$`, MatchMode: "regex"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"cdn.example.net."},
+		},
+		{
+			name:       "NS",
+			check:      &DNSCheck{Domain: "example.com", Type: "NS", Expected: "ns2.example.com"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"ns1.example.com.", "ns2.example.com."},
+		},
+		{
+			name:       "TXT",
+			check:      &DNSCheck{Domain: "example.com", Type: "TXT", Expected: "V=SPF1"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"v=spf1 include:_spf.example.net ~all"},
+		},
+		{
+			name:       "TXT exact rejects a partial policy",
+			check:      &DNSCheck{Domain: "example.com", Type: "TXT", Expected: "v=spf1 include:_spf.example.net", MatchMode: "exact"},
+			wantStatus: "FAIL",
+			wantRecs:   []string{"v=spf1 include:_spf.example.net ~all"},
+		},
+		{
+			name:       "TXT exact joins quoted strings",
+			check:      &DNSCheck{Domain: "example.com", Type: "TXT", Expected: `"v=spf1 include:" "_spf.example.net ~all"`, MatchMode: "exact"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"v=spf1 include:_spf.example.net ~all"},
+		},
+		{
+			name:       "TXT empty answer",
+			check:      &DNSCheck{Domain: "empty.example.com", Type: "TXT", Expected: "v=spf1"},
+			wantStatus: "FAIL",
+		},
+		{
+			name:       "empty answer fails resolution-only checks",
+			check:      &DNSCheck{Domain: "empty.example.com", Type: "TXT", RequireResolutionOnly: true},
+			wantStatus: "FAIL",
+		},
+		{
+			name:       "MX",
+			check:      &DNSCheck{Domain: "example.com", Type: "MX", Expected: "mail.example.com"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"mail.example.com."},
+		},
+		{
+			name:       "SRV",
+			check:      &DNSCheck{Domain: "_sip._tcp.example.com", Type: "SRV", Expected: "5060 sip.example.com"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"10 60 5060 sip.example.com."},
+		},
+		{
+			name:       "PTR",
+			check:      &DNSCheck{Domain: "192.0.2.25", Type: "PTR", Expected: "mail.example.com"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"mail.example.com."},
+		},
+		{
+			name:       "PTR needs an IP address",
+			check:      &DNSCheck{Domain: "mail.example.com", Type: "PTR", Expected: "mail.example.com"},
+			wantStatus: "UNSUPPORTED",
+			wantDetail: "PTR checks need an IP address as the domain",
+		},
+		{
+			name:       "SOA",
+			check:      &DNSCheck{Domain: "example.com", Type: "SOA", Expected: "2024010101"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"ns1.example.com. admin.example.com. 2024010101 3600 600 86400 300"},
+		},
+		{
+			name:       "CAA",
+			check:      &DNSCheck{Domain: "example.com", Type: "CAA", Expected: "0 issue letsencrypt.org", MatchMode: "exact"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"0 issue letsencrypt.org", "128 iodef mailto:security@example.com"},
+		},
+		{
+			name:       "CAA unexpected CA",
+			check:      &DNSCheck{Domain: "example.com", Type: "CAA", Expected: "issue digicert.com"},
+			wantStatus: "FAIL",
+			wantRecs:   []string{"0 issue letsencrypt.org", "128 iodef mailto:security@example.com"},
+		},
+		{
+			name:       "CAA without records",
+			check:      &DNSCheck{Domain: "nocaa.example.com", Type: "CAA", Expected: "letsencrypt.org"},
+			wantStatus: "FAIL",
+		},
+		{
+			name:       "CHAIN matches the terminal address",
+			check:      &DNSCheck{Domain: "www.example.com", Type: "CHAIN", Expected: "203.0.113.0/24"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"www.example.com. CNAME cdn.example.net.", "cdn.example.net. A 203.0.113.7", "cdn.example.net. AAAA 2001:db8::7"},
+		},
+		{
+			name:       "CHAIN records every CNAME hop",
+			check:      &DNSCheck{Domain: "alias.example.com", Type: "CHAIN", Expected: "2001:db8::7", MatchMode: "exact"},
+			wantStatus: "PASS",
+			wantRecs: []string{"alias.example.com. CNAME www.example.com.", "www.example.com. CNAME cdn.example.net.",
+				"cdn.example.net. A 203.0.113.7", "cdn.example.net. AAAA 2001:db8::7"},
+		},
+		{
+			name:       "CHAIN follows a DNAME",
+			check:      &DNSCheck{Domain: "web.old.example", Type: "CHAIN", Expected: "198.51.100.0/24"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"old.example. DNAME example.org.", "web.old.example. CNAME web.example.org.", "web.example.org. A 198.51.100.4"},
+		},
+		{
+			name:       "CHAIN without addresses",
+			check:      &DNSCheck{Domain: "nothing.example.com", Type: "CHAIN", Expected: "192.0.2.0/24"},
+			wantStatus: "NXDOMAIN",
+			wantError:  "lookup nothing.example.com on mock: no such host",
+		},
+		{
+			name:       "baseline unchanged",
+			check:      &DNSCheck{Domain: "example.com", Type: "NS", Baseline: true, baseline: []string{"NS2.example.com", "ns1.example.com."}},
+			wantStatus: "PASS",
+			wantRecs:   []string{"ns1.example.com.", "ns2.example.com."},
+		},
+		{
+			name:       "baseline changed",
+			check:      &DNSCheck{Domain: "example.com", Type: "A", Baseline: true, baseline: []string{"192.0.2.1", "192.0.2.3"}},
+			wantStatus: "CHANGED",
+			wantDetail: "+192.0.2.2,-192.0.2.3",
+			wantRecs:   []string{"192.0.2.1", "192.0.2.2"},
+		},
+		{
+			name:       "DNSSEC validated",
+			check:      &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.1", DNSSEC: true},
+			wantStatus: "PASS",
+			wantRecs:   []string{"192.0.2.1", "192.0.2.2"},
+		},
+		{
+			name:       "DNSSEC without a chain of trust",
+			check:      &DNSCheck{Domain: "www.example.com", Type: "CNAME", Expected: "cdn.example.net", DNSSEC: true},
+			wantStatus: "INSECURE",
+			wantRecs:   []string{"cdn.example.net."},
+		},
+		{
+			name:       "DNSSEC validation failure",
+			check:      &DNSCheck{Domain: "bogus.example.com", Type: "A", Expected: "192.0.2.1", DNSSEC: true},
+			err:        &net.DNSError{Err: "server misbehaving", Name: "bogus.example.com", Server: "mock", IsTemporary: true},
+			wantStatus: "BOGUS",
+			wantError:  "lookup bogus.example.com on mock: server misbehaving",
+		},
+		{
+			name:       "result count outside the range",
+			check:      &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.1", MaxResults: 1},
+			wantStatus: "FAIL",
+			wantDetail: "got 2 records, want at most 1",
+			wantRecs:   []string{"192.0.2.1", "192.0.2.2"},
+		},
+		{
+			name:       "TTL within the allowed range",
+			check:      &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.1", TTLMin: time.Minute, CaptureTTL: true},
+			wantStatus: "PASS",
+			wantRecs:   []string{"192.0.2.1", "192.0.2.2"},
+		},
+		{
+			name:       "TTL below the allowed range",
+			check:      &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.1", TTLMin: time.Hour, CaptureTTL: true},
+			wantStatus: "FAIL",
+			wantDetail: "192.0.2.1 has TTL 1m0s, want at least 1h0m0s",
+			wantRecs:   []string{"192.0.2.1", "192.0.2.2"},
+		},
+		{
+			name:       "expected RCODE",
+			check:      &DNSCheck{Domain: "refused.example.com", Type: "A", ExpectedRcode: "REFUSED"},
+			wantStatus: "PASS",
+		},
+		{
+			name:       "unexpected RCODE",
+			check:      &DNSCheck{Domain: "example.com", Type: "A", ExpectedRcode: "REFUSED"},
+			wantStatus: "FAIL",
+			wantDetail: "got rcode NOERROR, want REFUSED",
+			wantRecs:   []string{"192.0.2.1", "192.0.2.2"},
+		},
+		{
+			name:       "negated value absent",
+			check:      &DNSCheck{Domain: "example.com", Type: "A", Expected: "198.51.100.1", MatchMode: "exact", Negate: true},
+			wantStatus: "PASS",
+			wantRecs:   []string{"192.0.2.1", "192.0.2.2"},
+		},
+		{
+			name:       "negated value present",
+			check:      &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.2", MatchMode: "exact", Negate: true},
+			wantStatus: "FAIL",
+			wantDetail: "192.0.2.2 is present",
+			wantRecs:   []string{"192.0.2.1", "192.0.2.2"},
+		},
+		{
+			name:       "negated name does not exist",
+			check:      &DNSCheck{Domain: "missing.example.com", Type: "A", Expected: "192.0.2.1", Negate: true},
+			wantStatus: "PASS",
+			wantDetail: "name does not exist",
+		},
+		{
+			name:       "internationalized domain is queried in punycode",
+			check:      &DNSCheck{Domain: "bücher.example", Type: "A", Expected: "192.0.2.9"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"192.0.2.9"},
+		},
+		{
+			name:       "EXEC matches the command output",
+			check:      &DNSCheck{Domain: "example.com", Type: "EXEC", Expected: "example.com ok", Command: []string{"sh", "-c", `echo "$1 ok"; echo`, "sh"}},
+			wantStatus: "PASS",
+			wantRecs:   []string{"example.com ok"},
+		},
+		{
+			name:       "EXEC command fails",
+			check:      &DNSCheck{Domain: "example.com", Type: "EXEC", Expected: "ok", Command: []string{"sh", "-c", "echo broken >&2; exit 3", "sh"}},
+			wantStatus: "ERROR",
+			wantError:  "sh exited with status 3: broken",
+		},
+		{
+			name:       "records in resolver order",
+			check:      &DNSCheck{Domain: "rotated.example.com", Type: "A", Expected: "192.0.2.3"},
+			wantStatus: "PASS",
+			wantRecs:   []string{"192.0.2.20", "192.0.2.3"},
+		},
+		{
+			name:       "sorted records",
+			check:      &DNSCheck{Domain: "rotated.example.com", Type: "A", Expected: "192.0.2.3", SortRecords: true},
+			wantStatus: "PASS",
+			wantRecs:   []string{"192.0.2.3", "192.0.2.20"},
+		},
+		{
+			name:       "unsupported type",
+			check:      &DNSCheck{Domain: "example.com", Type: "HINFO", Expected: "x"},
+			wantStatus: "UNSUPPORTED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := tt.check
+			check.Timeout = time.Second
+			check.asciiDomain, _ = asciiDomain(check.Domain)
+			if check.MatchMode == "regex" {
+				check.expectedRegexp = regexp.MustCompile(check.Expected)
+			}
+
+			resolver := testResolver()
+			resolver.err = tt.err
+			result := PerformDNSCheck(check, resolver, "mock")
+			if result.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", result.Status, tt.wantStatus)
+			}
+			if result.Error != tt.wantError {
+				t.Errorf("error = %q, want %q", result.Error, tt.wantError)
+			}
+			if result.Detail != tt.wantDetail {
+				t.Errorf("detail = %q, want %q", result.Detail, tt.wantDetail)
+			}
+			if !reflect.DeepEqual(result.ActualResult, tt.wantRecs) {
+				t.Errorf("records = %q, want %q", result.ActualResult, tt.wantRecs)
+			}
+		})
+	}
+}
+
+func TestDedupedHistoryKeepsUptime(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	check := &DNSCheck{dedupeHistory: true}
+	// Ten polls a minute apart: six passes, two failures, then two passes
+	for i, status := range []string{"PASS", "PASS", "PASS", "PASS", "PASS", "PASS", "FAIL", "FAIL", "PASS", "PASS"} {
+		check.appendResult(CheckResult{
+			Status:       status,
+			Server:       "mock",
+			Timestamp:    start.Add(time.Duration(i) * time.Minute),
+			ActualResult: []string{"192.0.2.1"},
+		})
+	}
+
+	if len(check.History) != 3 {
+		t.Fatalf("history has %d entries, want 3", len(check.History))
+	}
+	if rate, total := successRate(check.History, start); total != 10 || rate != 0.8 {
+		t.Errorf("successRate over all polls = %v of %d, want 0.8 of 10", rate, total)
+	}
+	// From 00:03 onwards: three of the first run's passes, two failures, two passes
+	if rate, total := successRate(check.History, start.Add(3*time.Minute)); total != 7 || rate != 5.0/7 {
+		t.Errorf("successRate since 00:03 = %v of %d, want %v of 7", rate, total, 5.0/7)
+	}
+}
+
+func TestTransitionReportsOutage(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	check := &DNSCheck{Domain: "example.com", Type: "A"}
+	for i, status := range []string{"PASS", "FAIL", "ERROR", "FAIL"} {
+		check.History = append(check.History, CheckResult{Status: status, Server: "mock", Timestamp: start.Add(time.Duration(i) * time.Minute)})
+	}
+
+	alert, changed := transition(check, CheckResult{Status: "PASS", Server: "mock", Timestamp: start.Add(4 * time.Minute)})
+	if !changed || alert.Event != "recovered" {
+		t.Fatalf("transition = %q, changed %v; want recovered", alert.Event, changed)
+	}
+	if alert.Outage != 3*time.Minute {
+		t.Errorf("outage = %s, want 3m0s", alert.Outage)
+	}
+}
+
+func TestAlertCarriesDescription(t *testing.T) {
+	check := &DNSCheck{
+		Domain: "example.com", Type: "A", Expected: "192.0.2.1",
+		Description: "Payments apex; page the DBA", Runbook: "https://wiki.example.com/runbooks/payments",
+	}
+	if errs := validateCheck(check); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	check.History = []CheckResult{{Status: "PASS", Server: "mock"}}
+
+	alert, changed := transition(check, CheckResult{Status: "FAIL", Server: "mock"})
+	if !changed || alert.Description != check.Description || alert.Runbook != check.Runbook {
+		t.Fatalf("alert = %+v, want the check's description and runbook", alert)
+	}
+	details := alert.Details()
+	if !strings.Contains(details, "Runbook:  https://wiki.example.com/runbooks/payments\n") || !strings.HasSuffix(details, "\nPayments apex; page the DBA\n") {
+		t.Errorf("details = %q, want the runbook and description", details)
+	}
+
+	for _, runbook := range []string{"javascript:alert(1)", "wiki/payments", "https://"} {
+		invalid := &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.1", Runbook: runbook}
+		if errs := validateCheck(invalid); len(errs) != 1 {
+			t.Errorf("validating runbook %q returned %v, want one error", runbook, errs)
+		}
+	}
+}
+
+// recordingNotifier keeps every alert it is sent
+type recordingNotifier struct {
+	mu   sync.Mutex
+	sent []Alert
+}
+
+func (n *recordingNotifier) name() string           { return "recording" }
+func (n *recordingNotifier) wants(alert Alert) bool { return true }
+
+func (n *recordingNotifier) send(alert Alert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, alert)
+	return nil
+}
+
+// alerts returns the alerts sent so far, which are delivered concurrently,
+// ordered by the result that raised them and then by event
+func (n *recordingNotifier) alerts() []Alert {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	alerts := slices.Clone(n.sent)
+	slices.SortFunc(alerts, func(a, b Alert) int {
+		if c := a.Result.Timestamp.Compare(b.Result.Timestamp); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Event, b.Event)
+	})
+	return alerts
+}
+
+func TestSLOBurnAlerts(t *testing.T) {
+	config := &Config{}
+	config.Global.HistoryRetention = time.Hour
+	check := &DNSCheck{Domain: "example.com", Type: "A", SLO: &SLOConfig{Target: 90, Window: time.Hour, BurnRate: 2}}
+	config.Checks = []*DNSCheck{check}
+	webhook := &recordingNotifier{}
+	config.notifiers = []notifier{webhook}
+
+	start := time.Now().Add(-30 * time.Minute)
+	for i, status := range []string{"PASS", "PASS", "FAIL", "PASS", "PASS", "PASS", "PASS", "PASS", "PASS", "PASS"} {
+		config.updateStatus(check, CheckResult{Status: status, Server: "mock", Timestamp: start.Add(time.Duration(i) * time.Minute)})
+	}
+	config.notifications.Wait()
+
+	var events []string
+	for _, alert := range webhook.alerts() {
+		events = append(events, alert.Event)
+	}
+	// 1 of 3 failing burns the 10% budget at 3.3x; 1 of 5 is no longer above 2x
+	want := []string{"failed", "slo_burn", "recovered", "slo_recovered"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+	if got := testutil.ToFloat64(promCheckBurnRate.WithLabelValues(check.ID(), "example.com", "A")); got != check.BurnRate {
+		t.Errorf("burn rate gauge = %v, want %v", got, check.BurnRate)
+	}
+}
+
+// lockCheckingNotifier records whether the config lock was free when it was
+// asked about an alert
+type lockCheckingNotifier struct {
+	config   *Config
+	unlocked atomic.Bool
+}
+
+func (n *lockCheckingNotifier) name() string     { return "lock-checking" }
+func (n *lockCheckingNotifier) send(Alert) error { return nil }
+
+func (n *lockCheckingNotifier) wants(Alert) bool {
+	if n.config.mu.TryLock() {
+		n.config.mu.Unlock()
+		n.unlocked.Store(true)
+	}
+	return true
+}
+
+func TestUpdateStatusNotifiesWithoutTheLock(t *testing.T) {
+	config := &Config{}
+	config.Global.HistoryRetention = time.Hour
+	check := &DNSCheck{Domain: "example.com", Type: "A"}
+	config.Checks = []*DNSCheck{check}
+	checking := &lockCheckingNotifier{config: config}
+	config.notifiers = []notifier{checking}
+
+	config.updateStatus(check, CheckResult{Status: "PASS", Server: "mock", Timestamp: time.Now().Add(-time.Minute)})
+	config.updateStatus(check, CheckResult{Status: "FAIL", Server: "mock", Timestamp: time.Now()})
+	config.notifications.Wait()
+	if !checking.unlocked.Load() {
+		t.Error("alerts were sent while holding the config lock")
+	}
+}
+
+func TestPropagationResult(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	check := &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.9", Propagation: true}
+	poll := func(minute int, statuses ...string) CheckResult {
+		var results []CheckResult
+		for i, status := range statuses {
+			result := CheckResult{Status: status, Server: []string{"ns1", "ns2"}[i], Timestamp: start.Add(time.Duration(minute) * time.Minute)}
+			check.History = append(check.History, result)
+			results = append(results, result)
+		}
+		aggregate, ok := propagationResult(check, results)
+		if ok {
+			check.History = append(check.History, aggregate)
+		}
+		return aggregate
+	}
+
+	if result := poll(0, "FAIL", "FAIL"); result.Status != "" {
+		t.Errorf("before any server has the value: status = %q, want none", result.Status)
+	}
+	if result := poll(1, "PASS", "FAIL"); result.Status != "PROPAGATING" || result.Detail != "on 1 of 2 servers after 0s; waiting for ns2" {
+		t.Errorf("first poll with the value = %q %q", result.Status, result.Detail)
+	}
+	poll(2, "PASS", "FAIL")
+
+	last := CheckResult{Status: "PASS", Server: "ns2", Timestamp: start.Add(4 * time.Minute)}
+	check.History = append(check.History, CheckResult{Status: "PASS", Server: "ns1", Timestamp: last.Timestamp}, last)
+	result, _ := propagationResult(check, []CheckResult{check.History[len(check.History)-2], last})
+	if result.Status != "PROPAGATED" || result.Detail != "reached all 2 servers in 3m0s" {
+		t.Errorf("all servers have the value = %q %q", result.Status, result.Detail)
+	}
+
+	alert, changed := transition(check, result)
+	if !changed || alert.Event != "propagated" || alert.Propagation != 3*time.Minute {
+		t.Errorf("transition = %q after %s, changed %v; want propagated after 3m0s", alert.Event, alert.Propagation, changed)
+	}
+}
+
+// Run with -race: rendering the status page must not race with polls
+// recording results
+func TestStatusPageRendersDuringPolls(t *testing.T) {
+	config := &Config{}
+	config.Global.HistoryRetention = time.Hour
+	config.Global.UptimeWindows = []time.Duration{time.Hour}
+	check := &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.1", Interval: time.Minute, Status: "PENDING"}
+	config.Checks = []*DNSCheck{check}
+
+	tmpl, err := parseStatusTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			config.updateStatus(check, CheckResult{Status: "PASS", Server: "mock", Timestamp: time.Now(), ActualResult: []string{"192.0.2.1"}})
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		config.mu.RLock()
+		err := tmpl.Execute(io.Discard, config.newStatusPage(httptest.NewRequest("GET", "/", nil)))
+		config.mu.RUnlock()
+		if err != nil {
+			t.Fatalf("rendering status page: %v", err)
+		}
+		if history := check.Snapshot(); len(history) > 0 && history[len(history)-1].Status != "PASS" {
+			t.Errorf("latest status = %q, want PASS", history[len(history)-1].Status)
+		}
+	}
+	<-done
+}
+
+func TestTemplateHelpersTakeTheCheck(t *testing.T) {
+	check := &DNSCheck{Domain: "example.com", Type: "A", History: []CheckResult{
+		{Status: "PASS", Server: "mock", ActualResult: []string{"192.0.2.1"}},
+		{Status: "FAIL", Server: "mock", ActualResult: []string{"192.0.2.9"}},
+	}}
+	tmpl, err := template.New("custom").Funcs(templateFuncs).Parse(`{{with lastCheck .}}{{.Status}}{{end}} {{with resultDiff .}}{{.Added}}{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, check); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != "FAIL [192.0.2.9]" {
+		t.Errorf("rendered %q, want %q", got, "FAIL [192.0.2.9]")
+	}
+}
+
+func TestStatusPageDomainCards(t *testing.T) {
+	config := &Config{}
+	config.Global.UptimeWindows = []time.Duration{time.Hour}
+	config.Checks = []*DNSCheck{
+		{Domain: "example.com", Type: "A", Status: "PASS", Labels: Labels{"team": "web"}},
+		{Domain: "example.org", Type: "A", Status: "PASS", Labels: Labels{"team": "web"}},
+		{Domain: "Example.com.", Type: "MX", Status: "FAIL", Labels: Labels{"team": "web"}},
+		{Domain: "example.com", Type: "TXT", Status: "PASS", Labels: Labels{"team": "mail"}},
+	}
+
+	page := config.newStatusPage(httptest.NewRequest("GET", "/?group=team&view=domain", nil))
+	var cards []string
+	for _, group := range page.Groups {
+		for _, card := range group.Domains {
+			cards = append(cards, fmt.Sprintf("%s %s %d %s", group.Name, card.Domain, len(card.Checks), card.Status()))
+		}
+	}
+	want := []string{"team:mail example.com 1 PASS", "team:web example.com 2 FAIL", "team:web example.org 1 PASS"}
+	if !reflect.DeepEqual(cards, want) {
+		t.Errorf("cards = %q, want %q", cards, want)
+	}
+	if link := page.ViewLink(false); link != "/?group=team" {
+		t.Errorf("ViewLink(false) = %q, want /?group=team", link)
+	}
+
+	tmpl, err := parseStatusTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, page); err != nil {
+		t.Fatalf("rendering status page: %v", err)
+	}
+	if n := strings.Count(b.String(), `class="card-rows"`); n != 3 {
+		t.Errorf("page has %d domain cards, want 3", n)
+	}
+
+	if page := config.newStatusPage(httptest.NewRequest("GET", "/", nil)); page.Groups[0].Domains != nil || page.ViewLink(true) != "/?view=domain" {
+		t.Errorf("default page has cards %v and link %q", page.Groups[0].Domains, page.ViewLink(true))
+	}
+}
+
+func TestTimelineGroupsPollsByServer(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	config := &Config{servers: []string{"ns1", "ns2"}}
+	check := &DNSCheck{Domain: "example.com", Type: "A"}
+	// Servers are queried a little apart, and only the second poll disagrees
+	at := func(poll, offset int) time.Time {
+		return start.Add(time.Duration(poll)*time.Minute + time.Duration(offset)*time.Second)
+	}
+	check.History = []CheckResult{
+		{Status: "PASS", Server: "ns1", Timestamp: at(0, 0)},
+		{Status: "PASS", Server: "ns2", Timestamp: at(0, 2)},
+		{Status: "PASS", Server: "ns1", Timestamp: at(1, 0)},
+		{Status: "PASS", Server: "ns2", Timestamp: at(1, 3)},
+		{Status: "MISMATCH", Server: "ns1 vs ns2", Timestamp: at(1, 0)},
+		{Status: "PASS", Server: "ns1", Timestamp: at(2, 1)},
+		{Status: "FAIL", Server: "ns2", Timestamp: at(2, 0)},
+	}
+
+	polls := config.Timeline(check)
+	var got []string
+	for _, poll := range polls {
+		got = append(got, fmt.Sprintf("%s %s %d", poll.Timestamp.Format("15:04:05"), poll.Class, len(poll.Statuses)))
+	}
+	want := []string{"00:00:00 PASS 2", "00:01:00 MISMATCH 3", "00:02:00 FAIL 2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("timeline = %q, want %q", got, want)
+	}
+
+	// Notes go on the poll they follow; ones older than the timeline are left out
+	check.Annotations = []Annotation{
+		{Timestamp: at(1, 30), Note: "changed the record"},
+		{Timestamp: start.Add(-time.Hour), Note: "before the timeline"},
+		{Timestamp: at(0, 10), Note: "first poll"},
+	}
+	got = nil
+	for _, poll := range config.Timeline(check) {
+		var notes []string
+		for _, annotation := range poll.Annotations {
+			notes = append(notes, annotation.Note)
+		}
+		got = append(got, strings.Join(notes, ","))
+	}
+	want = []string{"first poll", "changed the record", ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("timeline annotations = %q, want %q", got, want)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/history/example.com/A?since=2024-01-01T00:01:00Z", nil)
+	req.SetPathValue("domain", "example.com")
+	req.SetPathValue("type", "A")
+	historyHandler(&Config{Checks: []*DNSCheck{check}})(rec, req)
+	var history []struct {
+		Server      string       `json:"server"`
+		Annotations []Annotation `json:"annotations"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+		t.Fatalf("decoding history: %v (%s)", err, rec.Body)
+	}
+	if len(history) != 5 || len(history[2].Annotations) != 1 || history[2].Annotations[0].Note != "changed the record" {
+		t.Errorf("history = %+v, want the note on the last result of the 00:01 poll", history)
+	}
+}
+
+func TestLoadHistoryFromLogSkipsExpiredLines(t *testing.T) {
+	check := &DNSCheck{Domain: "example.com", Type: "A"}
+	now := time.Now().Truncate(time.Second)
+
+	// Ten days of minutely polls, of which only the last hour is within retention
+	var lines strings.Builder
+	var recentOffset int64
+	for i := 10 * 24 * 60; i > 0; i-- {
+		timestamp := now.Add(-time.Duration(i) * time.Minute)
+		if recentOffset == 0 && timestamp.After(now.Add(-time.Hour)) {
+			recentOffset = int64(lines.Len())
+		}
+		lines.WriteString(formatLogEntry(check, CheckResult{Status: "PASS", Server: "mock", Timestamp: timestamp, ActualResult: []string{"192.0.2.1"}}, "tsv"))
+	}
+	logFile := filepath.Join(t.TempDir(), "example.com-A.log")
+	if err := os.WriteFile(logFile, []byte(lines.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := seekToCutoff(f, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if offset, _ := f.Seek(0, io.SeekCurrent); offset == 0 || offset > recentOffset {
+		t.Errorf("seekToCutoff left the file at %d, want a line start in (0, %d]", offset, recentOffset)
+	}
+
+	if err := loadHistoryFromLog(context.Background(), check, logFile, time.Hour, &loadedLines{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(check.History) != 59 {
+		t.Errorf("loaded %d results, want the 59 within the last hour", len(check.History))
+	}
+
+	capped := &DNSCheck{Domain: "example.com", Type: "A", MaxHistoryEntries: 10}
+	if err := loadHistoryFromLog(context.Background(), capped, logFile, 30*24*time.Hour, &loadedLines{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(capped.History) != 10 || !capped.History[9].Timestamp.Equal(now.Add(-time.Minute)) {
+		t.Errorf("capped history has %d results ending %v, want the newest 10", len(capped.History), capped.History[len(capped.History)-1].Timestamp)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := loadHistoryFromLog(ctx, &DNSCheck{}, logFile, 30*24*time.Hour, &loadedLines{}); err == nil {
+		t.Error("loading with a cancelled context succeeded, want an error")
+	}
+}
+
+func TestLoadHistoryFromLogSkipsDuplicatesAndPartialLines(t *testing.T) {
+	check := &DNSCheck{Domain: "example.com", Type: "A"}
+	now := time.Now().Truncate(time.Second)
+	line := func(minutesAgo int, server string) string {
+		return formatLogEntry(check, CheckResult{Status: "PASS", Server: server, Timestamp: now.Add(-time.Duration(minutesAgo) * time.Minute), ActualResult: []string{"192.0.2.1"}}, "tsv")
+	}
+
+	// The rotated log ends with the entry the current one starts with, the
+	// current one repeats an entry, and a crash cut the last one short
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "example.com-A.log")
+	rotated := line(4, "ns1") + line(3, "ns1")
+	current := line(3, "ns1") + line(2, "ns1") + line(2, "ns2") + line(2, "ns1") + line(1, "ns1")
+	cut := line(0, "ns1")
+	current += cut[:strings.Index(cut, "192.0.2.1")+5]
+	if err := os.WriteFile(logFile+".1", []byte(rotated), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(logFile, []byte(current), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loadHistoryFiles(context.Background(), check, logFile, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, result := range check.History {
+		got = append(got, fmt.Sprintf("%s@-%v", result.Server, now.Sub(result.Timestamp)))
+	}
+	want := []string{"ns1@-4m0s", "ns1@-3m0s", "ns1@-2m0s", "ns2@-2m0s", "ns1@-1m0s"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %v, want %v", got, want)
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	config := &Config{}
+	config.Global.MaxConcurrent = 2
+	config.servers = []string{"ns1", "ns2"}
+	config.resolvers = []Resolver{testResolver(), testResolver()}
+	config.tcpResolvers = config.resolvers
+	disabled := false
+	config.Checks = []*DNSCheck{
+		{Domain: "example.com", Type: "A", Expected: "192.0.2.1", Timeout: time.Second},
+		{Domain: "example.com", Type: "A", Expected: "198.51.100.1", Timeout: time.Second},
+		{Domain: "example.com", Type: "MX", Expected: "mail.example.com", Timeout: time.Second, Enabled: &disabled},
+	}
+
+	runs := config.RunOnce(context.Background())
+	if len(runs) != 2 {
+		t.Fatalf("got %d runs, want 2 (the disabled check is skipped)", len(runs))
+	}
+	if !runs[0].Passed() || runs[1].Passed() {
+		t.Errorf("passed = %v, %v, want true, false", runs[0].Passed(), runs[1].Passed())
+	}
+	if len(runs[1].Results) != 2 || runs[1].Results[1].Server != "ns2" {
+		t.Errorf("results = %+v, want one per server", runs[1].Results)
+	}
+	if len(runs[0].Check.History) != 0 {
+		t.Errorf("history has %d entries, want none recorded", len(runs[0].Check.History))
+	}
+
+	var summary strings.Builder
+	if failed := WriteRunSummary(&summary, runs); failed != 1 {
+		t.Errorf("WriteRunSummary reported %d failures, want 1", failed)
+	}
+	if !strings.HasSuffix(summary.String(), "1 of 2 checks passed\n") {
+		t.Errorf("summary = %q", summary.String())
+	}
+}
+
+func TestLoadConfigAppliesTemplates(t *testing.T) {
+	dir := t.TempDir()
+	config := `
+global:
+  log_dir: "` + filepath.Join(dir, "logs") + `"
+  retries: 3
+templates:
+  web:
+    type: A
+    expected: 192.0.2.1
+    interval: 10m
+    negate: true
+    labels: {team: web, env: prod}
+include: ["more.yaml"]
+checks:
+  - domain: www.example.com
+    template: web
+  - domain: api.example.com
+    template: web
+    negate: false
+    retries: 1
+    labels: {env: staging}
+`
+	more := `
+checks:
+  - domain: shop.example.com
+    template: web
+    expected: 192.0.2.2
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "more.yaml"), []byte(more), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, check := range loaded.Checks {
+		got = append(got, fmt.Sprintf("%s %s %s %v negate=%v retries=%d %s", check.Domain, check.Type, check.Expected, check.Interval, check.Negate, check.Retries, check.Labels))
+	}
+	want := []string{
+		"www.example.com A 192.0.2.1 10m0s negate=true retries=3 map[env:prod team:web]",
+		"api.example.com A 192.0.2.1 10m0s negate=false retries=1 map[env:staging team:web]",
+		"shop.example.com A 192.0.2.2 10m0s negate=true retries=3 map[env:prod team:web]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("checks =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	unknown := strings.Replace(config, "template: web\n  - domain: api", "template: wbe\n  - domain: api", 1)
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(unknown), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "config.yaml")); err == nil || !strings.Contains(err.Error(), `unknown template "wbe"`) {
+		t.Errorf("loading a check with an unknown template returned %v", err)
+	}
+}
+
+func TestLoadConfigExpandsEnvInValues(t *testing.T) {
+	t.Setenv("DNSMON_RETRIES", "4")
+	t.Setenv("DNSMON_EXPECTED", "192.0.2.1\n  - domain: injected.example.com")
+	dir := t.TempDir()
+	config := `
+# Comments may mention ${DNSMON_UNSET} without it being set
+global:
+  log_dir: "` + filepath.Join(dir, "logs") + `"
+  retries: ${DNSMON_RETRIES}
+checks:
+  - domain: www.example.com
+    type: TXT
+    expected: "${DNSMON_EXPECTED}"
+  - domain: api.example.com
+    type: TXT
+    expected: $${literal}
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Global.Retries != 4 {
+		t.Errorf("retries = %d, want 4", loaded.Global.Retries)
+	}
+	if len(loaded.Checks) != 2 || loaded.Checks[0].Expected != os.Getenv("DNSMON_EXPECTED") || loaded.Checks[1].Expected != "${literal}" {
+		t.Errorf("checks = %v, want the variable's value kept as one expected value and the escape unescaped", loaded.Checks)
+	}
+}
+
+func TestLoadConfigReportsAllProblems(t *testing.T) {
+	dir := t.TempDir()
+	config := `
+global:
+  log_dir: "` + filepath.Join(dir, "logs") + `"
+  log_format: xml
+  resolver_mode: carrier-pigeon
+checks:
+  - domain: www.example.com
+    type: BOGUS
+    expected: 192.0.2.1
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	if err == nil {
+		t.Fatal("loading an invalid config succeeded")
+	}
+	for _, want := range []string{"3 problem(s)", `invalid log_format "xml"`, `invalid resolver_mode "carrier-pigeon"`, `unsupported type "BOGUS"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestReloadBeforeMonitorMarksDroppedChecksRemoved(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.yaml")
+	config := `
+global:
+  log_dir: "` + filepath.Join(dir, "logs") + `"
+checks:
+  - domain: www.example.com
+    type: A
+    expected: 192.0.2.1
+  - domain: api.example.com
+    type: A
+    expected: 192.0.2.2
+`
+	if err := os.WriteFile(filename, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped := loaded.Checks[1]
+
+	// SIGHUP can arrive before Monitor has created the scheduler
+	config = strings.Replace(config, "  - domain: api.example.com\n    type: A\n    expected: 192.0.2.2\n", "", 1)
+	if err := os.WriteFile(filename, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.reload(filename); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Checks) != 1 || loaded.Checks[0].Domain != "www.example.com" {
+		t.Errorf("checks after reload = %v, want only www.example.com", loaded.Checks)
+	}
+	if !dropped.removed.Load() {
+		t.Error("the check dropped by the reload is not marked removed")
+	}
+}
+
+func TestDiscoveredChecksExpectTheRecordSet(t *testing.T) {
+	d := &DiscoverConfig{CheckInterval: time.Minute}
+	checks := d.discoveredChecks(map[string][]string{
+		"example.com./A":         {"192.0.2.2", "192.0.2.1"},
+		"lookalike.com./A":       {"1.2.3.4"},
+		"rotated.example.com./A": {"192.0.2.3"},
+	})
+
+	resolver := testResolver()
+	want := map[string]string{"example.com": "PASS", "lookalike.com": "FAIL", "rotated.example.com": "DRIFT"}
+	for _, check := range checks {
+		if errs := validateCheck(check); len(errs) > 0 {
+			t.Fatalf("%s: %v", check.ID(), errs)
+		}
+		if result := PerformDNSCheck(check, resolver, "mock"); result.Status != want[check.Domain] {
+			t.Errorf("%s: status = %s (%s), want %s", check.Domain, result.Status, result.Detail, want[check.Domain])
+		}
+	}
+}
+
+func TestCheckStatusReportsNextRun(t *testing.T) {
+	config := &Config{scheduler: newScheduler()}
+	check := &DNSCheck{Domain: "example.com", Type: "A", Interval: time.Minute}
+	if status := config.checkStatus(check); status.NextRun != nil || status.Interval != "1m0s" {
+		t.Errorf("before monitoring: next_run = %v, interval = %q, want none and 1m0s", status.NextRun, status.Interval)
+	}
+
+	at := time.Now().Add(30 * time.Second)
+	config.scheduler.add(check, at)
+	config.monitoring.Store(true)
+	if status := config.checkStatus(check); status.NextRun == nil || !status.NextRun.Equal(at) {
+		t.Errorf("next_run = %v, want %v", status.NextRun, at)
+	}
+
+	config.scheduler.remove(check)
+	if next := config.NextRun(check); !next.IsZero() {
+		t.Errorf("NextRun after removal = %v, want zero", next)
+	}
+}
+
+func TestMarkStale(t *testing.T) {
+	config := &Config{scheduler: newScheduler()}
+	now := time.Now()
+	fresh := &DNSCheck{Domain: "fresh.example.com", Type: "A", Interval: time.Minute, Timeout: 5 * time.Second, Status: "PASS", LastCheck: now.Add(3 * time.Minute)}
+	stuck := &DNSCheck{Domain: "stuck.example.com", Type: "A", Interval: time.Minute, Timeout: 5 * time.Second, Status: "PASS", LastCheck: now.Add(-time.Hour)}
+	never := &DNSCheck{Domain: "never.example.com", Type: "A", Interval: time.Minute, Timeout: 5 * time.Second, Status: "PENDING"}
+	unscheduled := &DNSCheck{Domain: "off.example.com", Type: "A", Interval: time.Minute, Status: "DISABLED"}
+	config.Checks = []*DNSCheck{fresh, stuck, never, unscheduled}
+	for _, check := range config.Checks[:3] {
+		config.scheduler.add(check, now)
+	}
+
+	// Within two intervals plus a poll's timeout of being scheduled, nothing is stale yet
+	config.markStale(now.Add(2 * time.Minute))
+	if stuck.Status != "PASS" || never.Status != "PENDING" {
+		t.Errorf("statuses right after scheduling = %s, %s, want PASS, PENDING", stuck.Status, never.Status)
+	}
+
+	config.markStale(now.Add(4 * time.Minute))
+	for check, want := range map[*DNSCheck]string{fresh: "PASS", stuck: "STALE", never: "STALE", unscheduled: "DISABLED"} {
+		if check.Status != want {
+			t.Errorf("%s status = %s, want %s", check.Domain, check.Status, want)
+		}
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	config := &Config{}
+	config.Global.Port = ":8080"
+	if addr := config.ListenAddr(); addr != ":8080" {
+		t.Errorf("ListenAddr with only port = %q, want :8080", addr)
+	}
+	config.Global.ListenAddr = "127.0.0.1:9090"
+	if addr := config.ListenAddr(); addr != "127.0.0.1:9090" {
+		t.Errorf("ListenAddr with listen_addr = %q, want 127.0.0.1:9090", addr)
+	}
+	if err := config.SetListenAddr("[::1]:7070"); err != nil {
+		t.Fatal(err)
+	}
+	if addr := config.ListenAddr(); addr != "[::1]:7070" {
+		t.Errorf("ListenAddr with an override = %q, want [::1]:7070", addr)
+	}
+
+	for _, addr := range []string{"localhost:http", ":0", "[::1]:8080"} {
+		if err := validateListenAddr(addr); err != nil {
+			t.Errorf("validateListenAddr(%q) = %v", addr, err)
+		}
+	}
+	for _, addr := range []string{"127.0.0.1", "127.0.0.1:", "127.0.0.1:70000", "::1:80"} {
+		if err := validateListenAddr(addr); err == nil {
+			t.Errorf("validateListenAddr(%q) succeeded, want an error", addr)
+		}
+	}
+}
+
+func TestTLSSettings(t *testing.T) {
+	for _, global := range []struct{ cert, key, redirect string }{
+		{cert: "cert.pem"},
+		{key: "key.pem"},
+		{redirect: ":80"},
+		{cert: filepath.Join(t.TempDir(), "missing.pem"), key: filepath.Join(t.TempDir(), "missing.pem")},
+	} {
+		config := &Config{}
+		config.Global.TLSCert, config.Global.TLSKey, config.Global.HTTPRedirectAddr = global.cert, global.key, global.redirect
+		if err := config.loadTLS(); err == nil {
+			t.Errorf("loadTLS with %+v succeeded, want an error", global)
+		}
+	}
+	if config := (&Config{}); config.loadTLS() != nil || config.TLSConfig() != nil {
+		t.Error("TLS is enabled without tls_cert and tls_key")
+	}
+
+	config := &Config{}
+	config.Global.ListenAddr = ":8443"
+	for target, want := range map[string]string{
+		"http://status.example.com/api/status?check=a": "https://status.example.com:8443/api/status?check=a",
+		"http://status.example.com:80/":                "https://status.example.com:8443/",
+		"http://[2001:db8::1]/":                        "https://[2001:db8::1]:8443/",
+	} {
+		rec := httptest.NewRecorder()
+		config.RedirectHandler().ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != 308 || rec.Header().Get("Location") != want {
+			t.Errorf("redirect for %s = %d %q, want 308 %q", target, rec.Code, rec.Header().Get("Location"), want)
+		}
+	}
+	config.Global.ListenAddr = "[::]:443"
+	rec := httptest.NewRecorder()
+	config.RedirectHandler().ServeHTTP(rec, httptest.NewRequest("GET", "http://[2001:db8::1]/", nil))
+	if want := "https://[2001:db8::1]/"; rec.Header().Get("Location") != want {
+		t.Errorf("redirect to port 443 = %q, want %q", rec.Header().Get("Location"), want)
+	}
+}
+
+func TestLeaderResignReleasesLease(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "leader.lock")
+	first := newLeaderElector(LeaderElectionConfig{LockFile: lockFile, LeaseDuration: time.Hour, InstanceID: "first"})
+	second := newLeaderElector(LeaderElectionConfig{LockFile: lockFile, LeaseDuration: time.Hour, InstanceID: "second"})
+
+	first.campaign()
+	first.start()
+	second.campaign()
+	if !first.leader.Load() || second.leader.Load() {
+		t.Fatalf("leaders = %v, %v, want only the first", first.leader.Load(), second.leader.Load())
+	}
+
+	first.resign()
+	second.campaign()
+	if first.leader.Load() || !second.leader.Load() {
+		t.Errorf("after resigning, leaders = %v, %v, want only the second", first.leader.Load(), second.leader.Load())
+	}
+}
+
+func TestFileLockTakesOverExpiredLeaseOnce(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "leader.lock")
+	stale := fmt.Sprintf("gone\t%s\n", time.Now().Add(-time.Minute).Format(time.RFC3339Nano))
+	if err := os.WriteFile(lockFile, []byte(stale), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	acquired := make([]bool, 8)
+	for i := range acquired {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock := &fileLock{path: lockFile, id: fmt.Sprintf("replica-%d", i), lease: time.Hour}
+			ok, err := lock.TryAcquire()
+			if err != nil {
+				t.Errorf("replica-%d: %v", i, err)
+			}
+			acquired[i] = ok
+		}()
+	}
+	wg.Wait()
+
+	var leaders []string
+	for i, ok := range acquired {
+		if ok {
+			leaders = append(leaders, fmt.Sprintf("replica-%d", i))
+		}
+	}
+	if len(leaders) != 1 {
+		t.Fatalf("leaders = %v, want exactly one", leaders)
+	}
+	data, _ := os.ReadFile(lockFile)
+	if holder, _, _ := parseLease(data); holder != leaders[0] {
+		t.Errorf("lease held by %q, want %q", holder, leaders[0])
+	}
+
+	other := &fileLock{path: lockFile, id: "late", lease: time.Hour}
+	if ok, _ := other.TryAcquire(); ok {
+		t.Error("a replica overwrote a lease it does not hold")
+	}
+}
+
+func TestWriteAPIRequiresBearerAPIToken(t *testing.T) {
+	config := &Config{}
+	config.Global.APIToken = "write"
+	config.Global.Auth.Token = "read"
+	config.Checks = []*DNSCheck{{Domain: "example.com", Type: "A"}}
+
+	tests := []struct {
+		authorization string
+		want          int
+	}{
+		{"write", http.StatusUnauthorized},
+		{"Bearer read", http.StatusUnauthorized},
+		{"Basic d3JpdGU6d3JpdGU=", http.StatusUnauthorized},
+		// Authorized, so the missing note is what gets rejected
+		{"Bearer write", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/annotate?name=example.com/A", nil)
+		req.Header.Set("Authorization", tt.authorization)
+		rec := httptest.NewRecorder()
+		annotateHandler(config).ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("Authorization %q: status %d, want %d", tt.authorization, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestDeleteCheckByID(t *testing.T) {
+	config := &Config{scheduler: newScheduler()}
+	config.Global.APIToken = "token"
+	config.monitoring.Store(true)
+	primary := &DNSCheck{Name: "www-primary", Domain: "www.example.com", Type: "A"}
+	secondary := &DNSCheck{Name: "www-secondary", Domain: "www.example.com", Type: "A"}
+	config.Checks = []*DNSCheck{primary, secondary}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /api/checks/{id}", deleteCheckHandler(config))
+	mux.HandleFunc("DELETE /api/checks/{domain}/{type}", deleteCheckHandler(config))
+	remove := func(target string) int {
+		req := httptest.NewRequest("DELETE", target, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := remove("/api/checks/www.example.com/A"); code != http.StatusConflict {
+		t.Errorf("deleting an ambiguous domain and type returned %d, want 409", code)
+	}
+	if code := remove("/api/checks/www-secondary"); code != http.StatusNoContent {
+		t.Fatalf("deleting by ID returned %d, want 204", code)
+	}
+	if len(config.Checks) != 1 || config.Checks[0] != primary || !secondary.removed.Load() || primary.removed.Load() {
+		t.Errorf("checks = %v, want only www-primary left", config.Checks)
+	}
+	// Now unambiguous, so the domain and type are enough
+	if code := remove("/api/checks/www.example.com/A"); code != http.StatusNoContent || len(config.Checks) != 0 {
+		t.Errorf("deleting the remaining check returned %d with %d checks left", code, len(config.Checks))
+	}
+}
+
+func TestHistoryByID(t *testing.T) {
+	config := &Config{}
+	primary := &DNSCheck{Name: "www-primary", Domain: "www.example.com", Type: "A", History: []CheckResult{{Status: "PASS", Server: "ns1"}}}
+	secondary := &DNSCheck{Name: "www-secondary", Domain: "www.example.com", Type: "A", History: []CheckResult{{Status: "FAIL", Server: "ns2"}}}
+	config.Checks = []*DNSCheck{primary, secondary}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/history/{id}", historyHandler(config))
+	mux.HandleFunc("GET /api/history/{domain}/{type}", historyHandler(config))
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	if rec := get("/api/history/www.example.com/A"); rec.Code != http.StatusConflict {
+		t.Errorf("ambiguous domain and type returned %d, want 409", rec.Code)
+	}
+	for _, target := range []string{"/api/history/www-secondary", "/api/history/www.example.com/A?name=www-secondary"} {
+		rec := get(target)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"server":"ns2"`) || strings.Contains(rec.Body.String(), "ns1") {
+			t.Errorf("%s returned %d %s, want the secondary check's history", target, rec.Code, rec.Body)
+		}
+	}
+}
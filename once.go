@@ -0,0 +1,130 @@
+package dnsmonitor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// CheckRun is the outcome of running one check once: a result per server, in
+// configuration order, followed by a MISMATCH result if the servers disagree
+type CheckRun struct {
+	Check   *DNSCheck
+	Results []CheckResult
+}
+
+// Passed reports whether every server passed and agreed
+func (run CheckRun) Passed() bool {
+	for _, result := range run.Results {
+		if statusClass(result.Status) != "PASS" {
+			return false
+		}
+	}
+	return len(run.Results) > 0
+}
+
+// RunOnce runs every enabled check once against every server, in config order,
+// for one-shot validation such as a CI step. Results are not recorded: history,
+// logs, baselines, metrics and alerts are left alone. Checks that have not
+// started when ctx is cancelled are left out.
+func (c *Config) RunOnce(ctx context.Context) []CheckRun {
+	if c.resolvers == nil {
+		c.setupResolvers()
+	}
+
+	var checks []*DNSCheck
+	for _, check := range c.Checks {
+		if !check.Disabled() {
+			checks = append(checks, check)
+		}
+	}
+
+	runs := make([]CheckRun, len(checks))
+	started := make([]bool, len(checks))
+	workers := make(chan struct{}, c.Global.MaxConcurrent)
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		started[i] = true
+		wg.Add(1)
+		go func(i int, check *DNSCheck) {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			results := c.queryServers(ctx, check)
+			if !check.Propagation {
+				if mismatch, ok := compareServers(check, results); ok {
+					results = append(results, mismatch)
+				}
+			}
+			runs[i] = CheckRun{Check: check, Results: results}
+		}(i, check)
+	}
+	wg.Wait()
+
+	var done []CheckRun
+	for i, run := range runs {
+		if started[i] {
+			done = append(done, run)
+		}
+	}
+	return done
+}
+
+// WriteRunSummary writes a line per check run, with what each failing server
+// returned, followed by a count of the checks that passed. It returns how many
+// failed.
+func WriteRunSummary(w io.Writer, runs []CheckRun) int {
+	failed := 0
+	for _, run := range runs {
+		if run.Passed() {
+			fmt.Fprintf(w, "%-11s %s\n", "PASS", run.Check.ID())
+			continue
+		}
+		failed++
+
+		status := "PENDING"
+		for _, result := range run.Results {
+			if statusClass(result.Status) != "PASS" {
+				status = result.Status
+				break
+			}
+		}
+		fmt.Fprintf(w, "%-11s %s\n", status, run.Check.ID())
+		for _, result := range run.Results {
+			if statusClass(result.Status) == "PASS" {
+				continue
+			}
+			server := result.Server
+			if server == "" {
+				server = "system resolver"
+			}
+			fmt.Fprintf(w, "            %s %s: %s\n", server, result.Status, runOutcome(result))
+		}
+	}
+	fmt.Fprintf(w, "%d of %d checks passed\n", len(runs)-failed, len(runs))
+	return failed
+}
+
+// runOutcome describes a result for the summary: its error or detail, or else
+// the records it got
+func runOutcome(result CheckResult) string {
+	switch {
+	case result.Error != "":
+		return result.Error
+	case result.Detail != "":
+		return result.Detail
+	case len(result.ActualResult) > 0:
+		return "got " + strings.Join(result.ActualResult, ", ")
+	}
+	return "no records"
+}
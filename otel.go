@@ -0,0 +1,143 @@
+package dnsmonitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type OTelConfig struct {
+	Endpoint    string `yaml:"endpoint"`
+	Insecure    bool   `yaml:"insecure"`
+	ServiceName string `yaml:"service_name"`
+}
+
+type otelExporter struct {
+	tracer   trace.Tracer
+	checks   metric.Int64Counter
+	latency  metric.Float64Histogram
+	status   metric.Int64Gauge
+	burnRate metric.Float64Gauge
+	shutdown func(context.Context) error
+}
+
+// newOTelExporter sets up OTLP/HTTP trace and metric exporters for check results
+func newOTelExporter(cfg OTelConfig) (*otelExporter, error) {
+	ctx := context.Background()
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "dns-monitor"
+	}
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("error building OTel resource: %v", err)
+	}
+
+	traceOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	metricOpts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		traceOpts = append(traceOpts, otlptracehttp.WithInsecure())
+		metricOpts = append(metricOpts, otlpmetrichttp.WithInsecure())
+	}
+
+	traceExporter, err := otlptracehttp.New(ctx, traceOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP trace exporter: %v", err)
+	}
+	metricExporter, err := otlpmetrichttp.New(ctx, metricOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP metric exporter: %v", err)
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(traceExporter),
+		sdktrace.WithResource(res),
+	)
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)),
+		sdkmetric.WithResource(res),
+	)
+
+	meter := meterProvider.Meter("dns-monitor")
+	checks, err := meter.Int64Counter("dns_monitor.checks",
+		metric.WithDescription("Number of DNS checks performed"))
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTel counter: %v", err)
+	}
+	latency, err := meter.Float64Histogram("dns_monitor.check.duration",
+		metric.WithDescription("DNS check latency"), metric.WithUnit("ms"))
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTel histogram: %v", err)
+	}
+	status, err := meter.Int64Gauge("dns_monitor.check.status",
+		metric.WithDescription("1 when the latest check passed, 0 otherwise"))
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTel gauge: %v", err)
+	}
+	burnRate, err := meter.Float64Gauge("dns_monitor.check.burn_rate",
+		metric.WithDescription("SLO error budget burn rate over the SLO window"))
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTel gauge: %v", err)
+	}
+
+	return &otelExporter{
+		tracer:   tracerProvider.Tracer("dns-monitor"),
+		checks:   checks,
+		latency:  latency,
+		status:   status,
+		burnRate: burnRate,
+		shutdown: func(ctx context.Context) error {
+			return errors.Join(tracerProvider.Shutdown(ctx), meterProvider.Shutdown(ctx))
+		},
+	}, nil
+}
+
+// recordCheckResult emits a span covering the poll plus the check metrics
+func (o *otelExporter) recordCheckResult(check *DNSCheck, result CheckResult, burnRate float64) {
+	ctx := context.Background()
+	class := statusClass(result.Status)
+
+	attrs := []attribute.KeyValue{
+		attribute.String("dns.check", check.ID()),
+		attribute.String("dns.domain", check.Domain),
+		attribute.String("dns.type", check.Type),
+		attribute.String("dns.server", result.Server),
+	}
+
+	_, span := o.tracer.Start(ctx, "dns.check",
+		trace.WithTimestamp(result.Timestamp),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(
+			attribute.String("dns.status", class),
+			attribute.Int64("dns.latency_ms", result.Duration.Milliseconds()),
+			attribute.String("dns.records", strings.Join(result.ActualResult, ",")),
+		))
+	if class != "PASS" {
+		span.SetStatus(codes.Error, result.Describe())
+	}
+	span.End(trace.WithTimestamp(result.Timestamp.Add(result.Duration)))
+
+	passed := int64(0)
+	if class == "PASS" {
+		passed = 1
+	}
+	o.checks.Add(ctx, 1, metric.WithAttributes(append(attrs, attribute.String("dns.status", class))...))
+	o.latency.Record(ctx, float64(result.Duration.Microseconds())/1000, metric.WithAttributes(attrs...))
+	o.status.Record(ctx, passed, metric.WithAttributes(attrs...))
+	if check.SLO != nil {
+		o.burnRate.Record(ctx, burnRate, metric.WithAttributes(attrs[:3]...))
+	}
+}
@@ -0,0 +1,65 @@
+package dnsmonitor
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var checkLabels = []string{"check", "domain", "type", "server"}
+
+var (
+	promCheckStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dns_monitor_check_status",
+		Help: "Result of the latest check: 1 for PASS, 0 otherwise.",
+	}, checkLabels)
+	promChecksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_monitor_checks_total",
+		Help: "Number of checks performed.",
+	}, checkLabels)
+	promCheckFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_monitor_check_failures_total",
+		Help: "Number of checks that did not pass.",
+	}, checkLabels)
+	promCheckDuration = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dns_monitor_check_duration_seconds",
+		Help: "Duration of the latest check.",
+	}, checkLabels)
+	promCheckBurnRate = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dns_monitor_check_burn_rate",
+		Help: "SLO error budget burn rate over the SLO window, for checks with an SLO.",
+	}, []string{"check", "domain", "type"})
+)
+
+// recordPrometheus updates the metrics served on /metrics for a single poll,
+// plus the SLO burn rate when the check has an SLO
+func recordPrometheus(check *DNSCheck, result CheckResult) {
+	labels := prometheus.Labels{
+		"check":  check.ID(),
+		"domain": check.Domain,
+		"type":   check.Type,
+		"server": result.Server,
+	}
+
+	status := 0.0
+	if statusClass(result.Status) == "PASS" {
+		status = 1
+	} else {
+		promCheckFailures.With(labels).Inc()
+	}
+	promCheckStatus.With(labels).Set(status)
+	promChecksTotal.With(labels).Inc()
+	promCheckDuration.With(labels).Set(result.Duration.Seconds())
+	if check.SLO != nil {
+		promCheckBurnRate.WithLabelValues(check.ID(), check.Domain, check.Type).Set(check.BurnRate)
+	}
+}
+
+// forgetPrometheus drops the series of a check that is no longer monitored
+func forgetPrometheus(check *DNSCheck) {
+	labels := prometheus.Labels{"check": check.ID()}
+	promCheckStatus.DeletePartialMatch(labels)
+	promChecksTotal.DeletePartialMatch(labels)
+	promCheckFailures.DeletePartialMatch(labels)
+	promCheckDuration.DeletePartialMatch(labels)
+	promCheckBurnRate.DeletePartialMatch(labels)
+}
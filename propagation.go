@@ -0,0 +1,54 @@
+package dnsmonitor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// propagationServer is the server name recorded on a check's aggregate
+// PROPAGATING and PROPAGATED results, so transitions between them are tracked
+// like those of a single server
+const propagationServer = "all servers"
+
+// propagationResult summarizes how far the expected value of a propagation
+// check has spread, from when each server's current run of passing results
+// began. It reports PROPAGATING while only some servers return the value and
+// PROPAGATED with the spread once all of them do; before any server returns it
+// there is nothing to report. Callers must hold check.historyLock.
+func propagationResult(check *DNSCheck, results []CheckResult) (CheckResult, bool) {
+	var first, last time.Time
+	var actual []string
+	var waiting []string
+	for _, result := range results {
+		since := runStart(check.History, result.Server, func(class string) bool { return class == "PASS" })
+		if since.IsZero() {
+			waiting = append(waiting, result.Server)
+			continue
+		}
+		if first.IsZero() || since.Before(first) {
+			first, actual = since, result.ActualResult
+		}
+		if since.After(last) {
+			last = since
+		}
+	}
+	if first.IsZero() {
+		return CheckResult{}, false
+	}
+
+	result := CheckResult{
+		Server:       propagationServer,
+		Timestamp:    results[0].Timestamp,
+		ActualResult: actual,
+	}
+	if len(waiting) > 0 {
+		result.Status = "PROPAGATING"
+		result.Detail = fmt.Sprintf("on %d of %d servers after %s; waiting for %s",
+			len(results)-len(waiting), len(results), result.Timestamp.Sub(first).Round(time.Second), strings.Join(waiting, ", "))
+		return result, true
+	}
+	result.Status = "PROPAGATED"
+	result.Detail = fmt.Sprintf("reached all %d servers in %s", len(results), last.Sub(first).Round(time.Second))
+	return result, true
+}
@@ -0,0 +1,33 @@
+package dnsmonitor
+
+import (
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// rcodeError carries the response code of a check with an expected RCODE from
+// the lookup to the verdict. It is never retried.
+type rcodeError struct {
+	rcode int
+}
+
+// name is the RCODE's mnemonic, such as NXDOMAIN
+func (e *rcodeError) name() string {
+	return dns.RcodeToString[e.rcode]
+}
+
+func (e *rcodeError) Error() string {
+	return "server answered " + e.name()
+}
+
+// validRcode reports whether name is an RCODE such as NOERROR or REFUSED
+func validRcode(name string) bool {
+	_, ok := dns.StringToRcode[name]
+	return ok
+}
+
+// rcodeDetail describes an unexpected response code
+func rcodeDetail(got, want string) string {
+	return fmt.Sprintf("got rcode %s, want %s", got, want)
+}
@@ -0,0 +1,59 @@
+package dnsmonitor
+
+import (
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// normalizeRecord is the form records are compared in: lowercase and without
+// the trailing dot resolvers return on host names
+func normalizeRecord(record string) string {
+	return strings.TrimSuffix(strings.ToLower(record), ".")
+}
+
+// normalizeRecords returns the normalized records in sorted order, so answers
+// that only differ in ordering compare equal
+func normalizeRecords(records []string) []string {
+	normalized := make([]string, len(records))
+	for i, record := range records {
+		normalized[i] = normalizeRecord(record)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// sameRecordSet compares two record sets ignoring order, case and trailing dots
+func sameRecordSet(a, b []string) bool {
+	return slices.Equal(normalizeRecords(a), normalizeRecords(b))
+}
+
+// sortRecords puts records in a stable order when the check asks for it, so
+// answers a resolver rotates look the same from poll to poll. The aliases of
+// a CHAIN check stay in the order they were followed; only the addresses they
+// lead to are sorted.
+func (check *DNSCheck) sortRecords(records []string) {
+	if !check.SortRecords {
+		return
+	}
+	start := 0
+	if check.Type == "CHAIN" {
+		for i, record := range records {
+			if strings.Contains(record, " CNAME ") || strings.Contains(record, " DNAME ") {
+				start = i + 1
+			}
+		}
+	}
+	slices.SortFunc(records[start:], compareRecords)
+}
+
+// compareRecords orders addresses numerically and everything else as text
+func compareRecords(a, b string) int {
+	if x, err := netip.ParseAddr(a); err == nil {
+		if y, err := netip.ParseAddr(b); err == nil {
+			return x.Compare(y)
+		}
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,112 @@
+package dnsmonitor
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+)
+
+// sameSettings reports whether two checks are configured identically, ignoring
+// runtime state such as status and history
+func sameSettings(a, b *DNSCheck) bool {
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	for i := 0; i < va.NumField(); i++ {
+		field := va.Type().Field(i)
+		if !field.IsExported() || field.Tag.Get("yaml") == "-" {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			return false
+		}
+	}
+	return true
+}
+
+// reload re-reads the config file and applies changes to the checks: new checks
+// start monitoring, removed ones stop, changed ones restart, and unchanged ones
+// keep running on their current tickers. Checks added through the API are kept
+// unless the file now defines one with the same ID. Global settings need a restart.
+func (c *Config) reload(filename string) error {
+	fresh, err := LoadConfig(filename)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !reflect.DeepEqual(c.Global, fresh.Global) {
+		log.Printf("Warning: global settings changed in %s; restart to apply them", filename)
+	}
+	c.templates = fresh.templates
+	// A renewed certificate applies now; turning TLS on or off needs a restart
+	if cert := fresh.tlsCert.Load(); cert != nil && c.tlsCert.Load() != nil {
+		c.tlsCert.Store(cert)
+	}
+
+	current := make(map[string]*DNSCheck)
+	var discovered, runtime []*DNSCheck
+	for _, check := range c.Checks {
+		switch {
+		case check.Discovered:
+			discovered = append(discovered, check)
+		case check.Runtime:
+			runtime = append(runtime, check)
+		default:
+			current[check.ID()] = check
+		}
+	}
+
+	var checks []*DNSCheck
+	var added, changed int
+	for _, check := range fresh.Checks {
+		old, ok := current[check.ID()]
+		if ok && sameSettings(old, check) {
+			checks = append(checks, old)
+			delete(current, check.ID())
+			continue
+		}
+		if ok {
+			c.stopMonitor(old)
+			delete(current, check.ID())
+			changed++
+		} else {
+			added++
+		}
+		checks = append(checks, check)
+		c.startMonitor(check)
+	}
+
+	for _, check := range current {
+		c.stopMonitor(check)
+	}
+
+	defined := make(map[string]bool)
+	for _, check := range fresh.Checks {
+		defined[check.ID()] = true
+	}
+	for _, check := range runtime {
+		if defined[check.ID()] {
+			c.stopMonitor(check)
+			continue
+		}
+		checks = append(checks, check)
+	}
+
+	c.Checks = append(checks, discovered...)
+	log.Printf("Reloaded %s: %d added, %d changed, %d removed", filename, added, changed, len(current))
+	return nil
+}
+
+// ReloadOnSIGHUP reloads the config file every time the process receives SIGHUP
+func (c *Config) ReloadOnSIGHUP(filename string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := c.reload(filename); err != nil {
+			log.Printf("Error reloading config: %v", err)
+		}
+	}
+}
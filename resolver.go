@@ -0,0 +1,252 @@
+package dnsmonitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Resolver performs the lookups behind each record type. *net.Resolver covers
+// everything but SOA and CAA; wireResolver speaks DNS messages directly for transports
+// net.Resolver cannot use. Exchange sends a raw query for checks that need
+// header flags, such as DNSSEC validation.
+type Resolver interface {
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+	LookupNS(ctx context.Context, name string) ([]*net.NS, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupSOA(ctx context.Context, name string) (*dns.SOA, error)
+	LookupCAA(ctx context.Context, name string) ([]*dns.CAA, error)
+	Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error)
+}
+
+// netResolver adds SOA and CAA lookups to a net.Resolver bound to a single server
+type netResolver struct {
+	*net.Resolver
+	server   string
+	forceTCP bool
+}
+
+func (r netResolver) LookupSOA(ctx context.Context, name string) (*dns.SOA, error) {
+	return lookupSOA(ctx, name, r.server, r.forceTCP)
+}
+
+// NewResolver builds the resolver for one configured server according to
+// resolver_mode. forceTCP only matters for plain DNS; DoH and DoT always use TCP.
+func (c *Config) NewResolver(server string, forceTCP bool) Resolver {
+	switch c.Global.ResolverMode {
+	case "doh":
+		return newDoHResolver(server)
+	case "dot":
+		// Servers are validated when the config is loaded
+		addr, serverName, _ := dotServer(server, c.Global.TLSServerName)
+		return newDoTResolver(server, addr, serverName)
+	default:
+		forceTCP = forceTCP || c.Global.ResolverMode == "tcp"
+		return netResolver{createResolver(server, forceTCP), server, forceTCP}
+	}
+}
+
+// validateServer checks that a configured server suits the resolver mode
+func validateServer(mode, server string) error {
+	var err error
+	switch mode {
+	case "doh":
+		err = validateDoHURL(server)
+	case "dot":
+		_, _, err = dotServer(server, "")
+	default:
+		_, err = serverAddr(server)
+	}
+	return err
+}
+
+// wireResolver answers lookups by building DNS queries and handing them to an
+// exchange function, such as a DoH or DoT round trip. Failures are reported as
+// *net.DNSError so they are classified like net.Resolver errors.
+type wireResolver struct {
+	server   string
+	exchange func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error)
+}
+
+// query returns the answer section for name and qtype, including any CNAMEs
+// leading to it
+func (r *wireResolver) query(ctx context.Context, name string, qtype uint16) ([]dns.RR, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+
+	resp, err := r.exchange(ctx, msg)
+	if err != nil {
+		var netErr net.Error
+		timeout := errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+		return nil, &net.DNSError{Err: err.Error(), Name: name, Server: r.server,
+			IsTimeout: timeout, IsTemporary: !certError(err), UnwrapErr: err}
+	}
+
+	switch resp.Rcode {
+	case dns.RcodeSuccess:
+		return resp.Answer, nil
+	case dns.RcodeNameError:
+		return nil, &net.DNSError{Err: "no such host", Name: name, Server: r.server, IsNotFound: true}
+	case dns.RcodeServerFailure:
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, Server: r.server, IsTemporary: true}
+	default:
+		return nil, &net.DNSError{Err: dns.RcodeToString[resp.Rcode], Name: name, Server: r.server}
+	}
+}
+
+// answers is query filtered to records of type T, failing like net.Resolver
+// with "no such host" when there are none
+func answers[T dns.RR](ctx context.Context, r *wireResolver, name string, qtype uint16) ([]T, error) {
+	rrs, err := r.query(ctx, name, qtype)
+	if err != nil {
+		return nil, err
+	}
+	var matched []T
+	for _, rr := range rrs {
+		if record, ok := rr.(T); ok {
+			matched = append(matched, record)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: name, Server: r.server, IsNotFound: true}
+	}
+	return matched, nil
+}
+
+func (r *wireResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	var ips []net.IP
+	var lastErr error
+	if network == "ip" || network == "ip4" {
+		records, err := answers[*dns.A](ctx, r, host, dns.TypeA)
+		for _, a := range records {
+			ips = append(ips, a.A)
+		}
+		lastErr = err
+	}
+	if network == "ip" || network == "ip6" {
+		records, err := answers[*dns.AAAA](ctx, r, host, dns.TypeAAAA)
+		for _, aaaa := range records {
+			ips = append(ips, aaaa.AAAA)
+		}
+		lastErr = err
+	}
+	if len(ips) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("unsupported network %q", network)
+		}
+		return nil, lastErr
+	}
+	return ips, nil
+}
+
+// LookupCNAME follows the CNAME chain in an A query's answer to the canonical
+// name, which is the name itself when it has no CNAME
+func (r *wireResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	rrs, err := r.query(ctx, host, dns.TypeA)
+	if err != nil {
+		return "", err
+	}
+	name := dns.Fqdn(host)
+	for followed := 0; followed < len(rrs); followed++ {
+		next := ""
+		for _, rr := range rrs {
+			if cname, ok := rr.(*dns.CNAME); ok && strings.EqualFold(cname.Hdr.Name, name) {
+				next = cname.Target
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+		name = next
+	}
+	return name, nil
+}
+
+func (r *wireResolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	records, err := answers[*dns.NS](ctx, r, name, dns.TypeNS)
+	if err != nil {
+		return nil, err
+	}
+	ns := make([]*net.NS, len(records))
+	for i, record := range records {
+		ns[i] = &net.NS{Host: record.Ns}
+	}
+	return ns, nil
+}
+
+// LookupTXT joins the character-strings of each record, as net.Resolver does
+func (r *wireResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, err := answers[*dns.TXT](ctx, r, name, dns.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	txt := make([]string, len(records))
+	for i, record := range records {
+		txt[i] = strings.Join(record.Txt, "")
+	}
+	return txt, nil
+}
+
+func (r *wireResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	records, err := answers[*dns.MX](ctx, r, name, dns.TypeMX)
+	if err != nil {
+		return nil, err
+	}
+	mx := make([]*net.MX, len(records))
+	for i, record := range records {
+		mx[i] = &net.MX{Host: record.Mx, Pref: record.Preference}
+	}
+	sort.SliceStable(mx, func(i, j int) bool { return mx[i].Pref < mx[j].Pref })
+	return mx, nil
+}
+
+func (r *wireResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	target := name
+	if service != "" || proto != "" {
+		target = "_" + service + "._" + proto + "." + name
+	}
+	records, err := answers[*dns.SRV](ctx, r, target, dns.TypeSRV)
+	if err != nil {
+		return "", nil, err
+	}
+	srv := make([]*net.SRV, len(records))
+	for i, record := range records {
+		srv[i] = &net.SRV{Target: record.Target, Port: record.Port, Priority: record.Priority, Weight: record.Weight}
+	}
+	sort.SliceStable(srv, func(i, j int) bool { return srv[i].Priority < srv[j].Priority })
+	return dns.Fqdn(target), srv, nil
+}
+
+func (r *wireResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	reverse, err := dns.ReverseAddr(addr)
+	if err != nil {
+		return nil, &net.DNSError{Err: "unrecognized address", Name: addr, Server: r.server}
+	}
+	records, err := answers[*dns.PTR](ctx, r, reverse, dns.TypePTR)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(records))
+	for i, record := range records {
+		names[i] = record.Ptr
+	}
+	return names, nil
+}
+
+func (r *wireResolver) LookupSOA(ctx context.Context, name string) (*dns.SOA, error) {
+	records, err := answers[*dns.SOA](ctx, r, name, dns.TypeSOA)
+	if err != nil {
+		return nil, err
+	}
+	return records[0], nil
+}
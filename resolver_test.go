@@ -0,0 +1,369 @@
+package dnsmonitor
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	_ Resolver = netResolver{}
+	_ Resolver = (*wireResolver)(nil)
+	_ Resolver = (*mockResolver)(nil)
+)
+
+// mockResolver answers lookups from canned records so checks can be tested
+// without touching the network. Names without records fail with NXDOMAIN.
+type mockResolver struct {
+	ips   map[string][]net.IP
+	cname map[string]string
+	dname map[string]string
+	ns    map[string][]*net.NS
+	txt   map[string][]string
+	mx    map[string][]*net.MX
+	srv   map[string][]*net.SRV
+	ptr   map[string][]string
+	soa   map[string]*dns.SOA
+	caa   map[string][]*dns.CAA
+
+	// secure names get the AD bit from Exchange; bogus names fail with
+	// SERVFAIL unless checking is disabled
+	secure map[string]bool
+	bogus  map[string]bool
+
+	// ttl is the TTL Exchange gives the A records of a name
+	ttl map[string]uint32
+	// rcode is the response code Exchange answers a name with
+	rcode map[string]int
+	// chaos holds the TXT records Exchange answers class CH queries with
+	chaos map[string][]string
+	// exchanged records the messages sent with Exchange
+	exchanged []*dns.Msg
+
+	// err, when set, is returned by every lookup
+	err error
+
+	// mu guards calls and exchanged, since checks may query the mock concurrently
+	mu    sync.Mutex
+	calls int
+}
+
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, Server: "mock", IsNotFound: true}
+}
+
+// lookup returns the canned answer for name, or the configured error
+func lookup[T any](m *mockResolver, answers map[string]T, name string) (T, error) {
+	m.mu.Lock()
+	m.calls++
+	m.mu.Unlock()
+	var zero T
+	if m.err != nil {
+		return zero, m.err
+	}
+	answer, ok := answers[name]
+	if !ok {
+		return zero, notFound(name)
+	}
+	return answer, nil
+}
+
+func (m *mockResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	return lookup(m, m.ips, host)
+}
+
+func (m *mockResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	return lookup(m, m.cname, host)
+}
+
+func (m *mockResolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	return lookup(m, m.ns, name)
+}
+
+func (m *mockResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return lookup(m, m.txt, name)
+}
+
+func (m *mockResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return lookup(m, m.mx, name)
+}
+
+func (m *mockResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	srv, err := lookup(m, m.srv, name)
+	return name, srv, err
+}
+
+func (m *mockResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	return lookup(m, m.ptr, addr)
+}
+
+func (m *mockResolver) LookupSOA(ctx context.Context, name string) (*dns.SOA, error) {
+	return lookup(m, m.soa, name)
+}
+
+func (m *mockResolver) LookupCAA(ctx context.Context, name string) ([]*dns.CAA, error) {
+	return lookup(m, m.caa, name)
+}
+
+func (m *mockResolver) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	m.mu.Lock()
+	m.calls++
+	m.exchanged = append(m.exchanged, msg)
+	m.mu.Unlock()
+	resp := new(dns.Msg)
+	resp.SetReply(msg)
+	name := strings.TrimSuffix(msg.Question[0].Name, ".")
+	switch {
+	case m.bogus[name] && !msg.CheckingDisabled:
+		resp.Rcode = dns.RcodeServerFailure
+	case m.secure[name]:
+		resp.AuthenticatedData = true
+	}
+	if rcode, ok := m.rcode[name]; ok {
+		resp.Rcode = rcode
+		return resp, nil
+	}
+
+	if msg.Question[0].Qclass == dns.ClassCHAOS {
+		for _, txt := range m.chaos[name] {
+			resp.Answer = append(resp.Answer, &dns.TXT{Hdr: dns.RR_Header{Name: msg.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS}, Txt: []string{txt}})
+		}
+		return resp, nil
+	}
+
+	// Address queries follow aliases like a recursive resolver, answering with
+	// each DNAME and CNAME on the way before the target's addresses
+	qtype := msg.Question[0].Qtype
+	if qtype != dns.TypeA && qtype != dns.TypeAAAA {
+		return resp, nil
+	}
+	owner := msg.Question[0].Name
+	for {
+		if target, ok := m.cname[name]; ok {
+			resp.Answer = append(resp.Answer, &dns.CNAME{Hdr: dns.RR_Header{Name: owner, Rrtype: dns.TypeCNAME, Class: dns.ClassINET}, Target: target})
+			owner, name = target, strings.TrimSuffix(target, ".")
+			continue
+		}
+		followed := false
+		for zone, target := range m.dname {
+			if prefix, ok := strings.CutSuffix(name, "."+zone); ok {
+				synthesized := prefix + "." + target
+				resp.Answer = append(resp.Answer,
+					&dns.DNAME{Hdr: dns.RR_Header{Name: zone + ".", Rrtype: dns.TypeDNAME, Class: dns.ClassINET}, Target: target},
+					&dns.CNAME{Hdr: dns.RR_Header{Name: owner, Rrtype: dns.TypeCNAME, Class: dns.ClassINET}, Target: synthesized})
+				owner, name, followed = synthesized, strings.TrimSuffix(synthesized, "."), true
+				break
+			}
+		}
+		if !followed {
+			break
+		}
+	}
+	for _, ip := range m.ips[name] {
+		hdr := dns.RR_Header{Name: owner, Rrtype: qtype, Class: dns.ClassINET, Ttl: m.ttl[name]}
+		switch {
+		case qtype == dns.TypeA && ip.To4() != nil:
+			resp.Answer = append(resp.Answer, &dns.A{Hdr: hdr, A: ip})
+		case qtype == dns.TypeAAAA && ip.To4() == nil:
+			resp.Answer = append(resp.Answer, &dns.AAAA{Hdr: hdr, AAAA: ip})
+		}
+	}
+	return resp, nil
+}
+
+func TestPerformDNSCheckUsesResolver(t *testing.T) {
+	resolver := &mockResolver{ips: map[string][]net.IP{
+		"example.com": {net.ParseIP("192.0.2.1")},
+	}}
+	check := &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.1", Timeout: time.Second}
+
+	result := PerformDNSCheck(check, resolver, "mock")
+	if result.Status != "PASS" {
+		t.Errorf("status = %q, want PASS", result.Status)
+	}
+	if len(result.ActualResult) != 1 || result.ActualResult[0] != "192.0.2.1" {
+		t.Errorf("records = %v, want [192.0.2.1]", result.ActualResult)
+	}
+	if result.Attempts != 1 || resolver.calls != 1 {
+		t.Errorf("attempts = %d, calls = %d, want 1 each", result.Attempts, resolver.calls)
+	}
+}
+
+func TestPerformDNSCheckRetriesTemporaryErrors(t *testing.T) {
+	resolver := &mockResolver{err: &net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}}
+	check := &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.1", Timeout: time.Second, Retries: 2}
+
+	result := PerformDNSCheck(check, resolver, "mock")
+	if result.Status != "ERROR" {
+		t.Errorf("status = %q, want ERROR", result.Status)
+	}
+	if result.Attempts != 3 || resolver.calls != 3 {
+		t.Errorf("attempts = %d, calls = %d, want 3 each", result.Attempts, resolver.calls)
+	}
+}
+
+func TestPerformDNSCheckStopsRetryingWhenCancelled(t *testing.T) {
+	resolver := &mockResolver{err: &net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}}
+	check := &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.1", Timeout: time.Second, Retries: 5}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result := PerformDNSCheckContext(ctx, check, resolver, "mock")
+	// Without cancellation the backoff alone would take over 3s
+	if elapsed := time.Since(start); elapsed > time.Second || result.Attempts != 1 {
+		t.Errorf("returned after %s and %d attempts, want 1 attempt cut short by the context", elapsed, result.Attempts)
+	}
+}
+
+func TestPerformDNSCheckRetriesTransientPatterns(t *testing.T) {
+	refused := &net.DNSError{Err: "server refused the query", Name: "example.com"}
+	check := &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.1", Timeout: time.Second, Retries: 1}
+
+	resolver := &mockResolver{err: refused}
+	if result := PerformDNSCheck(check, resolver, "mock"); result.Status != "ERROR" || resolver.calls != 1 {
+		t.Errorf("without a pattern: status = %q, calls = %d, want ERROR after 1", result.Status, resolver.calls)
+	}
+
+	check.TransientErrors = []string{"REFUSED"}
+	resolver = &mockResolver{err: refused}
+	result := PerformDNSCheck(check, resolver, "mock")
+	if result.Status != "TRANSIENT" || result.Attempts != 2 || resolver.calls != 2 {
+		t.Errorf("with a pattern: status = %q, attempts = %d, calls = %d, want TRANSIENT after 2", result.Status, result.Attempts, resolver.calls)
+	}
+}
+
+func TestPerformDNSCheckSendsClientSubnet(t *testing.T) {
+	resolver := &mockResolver{ips: map[string][]net.IP{
+		"example.com": {net.ParseIP("192.0.2.1")},
+	}}
+	check := &DNSCheck{Domain: "example.com", Type: "A", Expected: "192.0.2.1", Timeout: time.Second}
+	_, check.clientSubnet, _ = net.ParseCIDR("198.51.100.0/24")
+
+	result := PerformDNSCheck(check, resolver, "mock")
+	if result.Status != "PASS" {
+		t.Fatalf("status = %q (%s), want PASS", result.Status, result.Describe())
+	}
+	if len(resolver.exchanged) != 1 {
+		t.Fatalf("sent %d queries, want 1", len(resolver.exchanged))
+	}
+	opt := resolver.exchanged[0].IsEdns0()
+	if opt == nil || len(opt.Option) != 1 {
+		t.Fatalf("query has no client subnet option: %v", resolver.exchanged[0])
+	}
+	ecs, ok := opt.Option[0].(*dns.EDNS0_SUBNET)
+	if !ok || ecs.SourceNetmask != 24 || !ecs.Address.Equal(net.ParseIP("198.51.100.0")) {
+		t.Errorf("client subnet option = %v, want 198.51.100.0/24", opt.Option[0])
+	}
+}
+
+func TestPerformDNSCheckQueriesClass(t *testing.T) {
+	resolver := &mockResolver{
+		txt:   map[string][]string{"version.bind": {"not the CH answer"}},
+		chaos: map[string][]string{"version.bind": {"9.18.24"}},
+	}
+	check := &DNSCheck{Domain: "version.bind", Type: "TXT", Expected: "9.18", Class: "chaos", Timeout: time.Second}
+	if errs := validateCheck(check); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if check.Class != "CH" {
+		t.Errorf("class = %q, want CH", check.Class)
+	}
+
+	result := PerformDNSCheck(check, resolver, "mock")
+	if result.Status != "PASS" {
+		t.Fatalf("status = %q (%s), want PASS", result.Status, result.Describe())
+	}
+	if len(resolver.exchanged) != 1 || resolver.exchanged[0].Question[0].Qclass != dns.ClassCHAOS {
+		t.Errorf("queries = %v, want one in class CH", resolver.exchanged)
+	}
+
+	invalid := &DNSCheck{Domain: "www.example.com", Type: "CHAIN", Expected: "192.0.2.1", Class: "CH"}
+	if errs := validateCheck(invalid); len(errs) != 1 {
+		t.Errorf("validating a CH CHAIN check returned %v, want one error", errs)
+	}
+}
+
+func TestTraceQueryStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	steps := traceQuery(ctx, "example.com", dns.TypeA, &mockResolver{})
+	if elapsed := time.Since(start); len(steps) != 0 || elapsed > time.Second {
+		t.Errorf("traced %d steps in %s after cancellation, want none", len(steps), elapsed)
+	}
+}
+
+func TestTraceHandlerAsksForTheCheckedName(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var questions []string
+	server := &dns.Server{PacketConn: conn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		mu.Lock()
+		questions = append(questions, req.Question[0].Name+" "+dns.TypeToString[req.Question[0].Qtype])
+		mu.Unlock()
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		resp.Authoritative = true
+		w.WriteMsg(resp)
+	})}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	roots := rootServers
+	rootServers = []string{conn.LocalAddr().String()}
+	defer func() { rootServers = roots }()
+
+	config := &Config{}
+	config.Checks = []*DNSCheck{
+		{Domain: "www.example.com", Type: "A", Expected: "192.0.2.1"},
+		{Domain: "www.example.com", Type: "CHAIN", Expected: "192.0.2.1"},
+		{Domain: "192.0.2.25", Type: "PTR", Expected: "mail.example.com"},
+	}
+	handler := traceHandler(config)
+	for _, check := range config.Checks {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/trace?name="+check.ID(), nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("tracing %s returned %d: %s", check.ID(), rec.Code, rec.Body)
+		}
+	}
+
+	want := []string{"www.example.com. A", "www.example.com. A", "25.2.0.192.in-addr.arpa. PTR"}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(questions, want) {
+		t.Errorf("questions = %q, want %q", questions, want)
+	}
+}
+
+func TestDoHServerSelectsHTTP3(t *testing.T) {
+	tests := []struct {
+		server   string
+		endpoint string
+		h3       bool
+		wantErr  bool
+	}{
+		{server: "https://dns.example/dns-query", endpoint: "https://dns.example/dns-query"},
+		{server: "https://dns.example/dns-query#h3", endpoint: "https://dns.example/dns-query", h3: true},
+		{server: "https://dns.example/dns-query#h2", wantErr: true},
+		{server: "http://dns.example/dns-query#h3", wantErr: true},
+	}
+	for _, tt := range tests {
+		endpoint, h3, err := dohServer(tt.server)
+		if (err != nil) != tt.wantErr || endpoint != tt.endpoint || h3 != tt.h3 {
+			t.Errorf("dohServer(%q) = %q, %v, %v", tt.server, endpoint, h3, err)
+		}
+	}
+}
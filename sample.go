@@ -0,0 +1,33 @@
+package dnsmonitor
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// sampleConfig is the commented example config written for new users
+//
+//go:embed config.yaml.example
+var sampleConfig []byte
+
+// WriteSampleConfig writes the sample config to path, refusing to overwrite an
+// existing file
+func WriteSampleConfig(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s already exists; remove it or pass -config to write the sample elsewhere", path)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing sample config: %v", err)
+	}
+	if _, err := f.Write(sampleConfig); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing sample config: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing sample config: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package dnsmonitor
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// checkQueue is a min-heap of checks ordered by their next run time
+type checkQueue []*DNSCheck
+
+func (q checkQueue) Len() int           { return len(q) }
+func (q checkQueue) Less(i, j int) bool { return q[i].nextRun.Before(q[j].nextRun) }
+
+func (q checkQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].queueIndex = i
+	q[j].queueIndex = j
+}
+
+func (q *checkQueue) Push(x any) {
+	check := x.(*DNSCheck)
+	check.queueIndex = len(*q)
+	*q = append(*q, check)
+}
+
+func (q *checkQueue) Pop() any {
+	old := *q
+	check := old[len(old)-1]
+	old[len(old)-1] = nil
+	check.queueIndex = -1
+	*q = old[:len(old)-1]
+	return check
+}
+
+// scheduler hands due checks to a fixed pool of workers, so the number of
+// concurrent polls stays bounded however many checks are configured. A check
+// is either waiting in the queue or being polled by a worker, never both.
+type scheduler struct {
+	mu    sync.Mutex
+	queue checkQueue
+	wake  chan struct{}
+	jobs  chan *DNSCheck
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{wake: make(chan struct{}, 1), jobs: make(chan *DNSCheck)}
+}
+
+// add schedules a check's first poll at the given time
+func (s *scheduler) add(check *DNSCheck, at time.Time) {
+	s.mu.Lock()
+	check.scheduled = true
+	check.scheduledAt = time.Now()
+	check.nextRun = at
+	heap.Push(&s.queue, check)
+	s.mu.Unlock()
+	s.notify()
+}
+
+// remove unschedules a check. A poll already in progress completes, but the
+// check is not queued again.
+func (s *scheduler) remove(check *DNSCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if check.scheduled && check.queueIndex >= 0 {
+		heap.Remove(&s.queue, check.queueIndex)
+	}
+	check.scheduled = false
+}
+
+// active reports whether a check is still scheduled
+func (s *scheduler) active(check *DNSCheck) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return check.scheduled
+}
+
+// next returns when a check is due to be polled, or the zero time when it is
+// not scheduled. A time in the past means it is being polled or waiting for a
+// free worker.
+func (s *scheduler) next(check *DNSCheck) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !check.scheduled {
+		return time.Time{}
+	}
+	return check.nextRun
+}
+
+// since returns when a check was scheduled, or false when it is not
+func (s *scheduler) since(check *DNSCheck) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return check.scheduledAt, check.scheduled
+}
+
+// done requeues a check after a poll, one interval after its previous run
+// time. A check that overran its interval runs again straight away.
+func (s *scheduler) done(check *DNSCheck) {
+	s.mu.Lock()
+	if !check.scheduled {
+		s.mu.Unlock()
+		return
+	}
+	check.nextRun = check.nextRun.Add(check.Interval)
+	if now := time.Now(); check.nextRun.Before(now) {
+		check.nextRun = now
+	}
+	heap.Push(&s.queue, check)
+	s.mu.Unlock()
+	s.notify()
+}
+
+// notify wakes the scheduler loop to recompute its next deadline
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run dispatches checks to workers as they come due until ctx is cancelled,
+// then closes the job channel so the workers exit once their polls finish
+func (s *scheduler) run(ctx context.Context) {
+	defer close(s.jobs)
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		var due *DNSCheck
+		wait := time.Hour
+		if len(s.queue) > 0 {
+			if wait = time.Until(s.queue[0].nextRun); wait <= 0 {
+				due = heap.Pop(&s.queue).(*DNSCheck)
+			}
+		}
+		s.mu.Unlock()
+
+		if due != nil {
+			// Blocks while every worker is busy, which is what caps concurrency
+			select {
+			case s.jobs <- due:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// NextRun returns when a check is next due to be polled, or the zero time when
+// it is not scheduled (disabled, or monitoring is not running). A time in the
+// past means a poll is in progress or waiting for a free worker; one that
+// stays in the past points at a stuck poll.
+func (c *Config) NextRun(check *DNSCheck) time.Time {
+	// The scheduler is set before monitoring starts
+	if !c.monitoring.Load() {
+		return time.Time{}
+	}
+	return c.scheduler.next(check)
+}
+
+// worker polls the checks the scheduler hands it until the job channel closes
+func (c *Config) worker(s *scheduler) {
+	for check := range s.jobs {
+		// The check may have been removed while waiting for a free worker
+		if !s.active(check) {
+			continue
+		}
+		if !c.isLeader() {
+			// Followers only mirror the leader's results from the shared log directory
+			c.refreshFromLog(check)
+		} else {
+			c.pollServers(check)
+		}
+		s.done(check)
+	}
+}
@@ -0,0 +1,58 @@
+package dnsmonitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// setupResolvers builds a resolver per configured server, plus a TCP-only one
+// for checks that set tcp. Without any configured server, checks use the
+// system resolver.
+func (c *Config) setupResolvers() {
+	c.servers = c.Global.DNSServers
+	if len(c.servers) == 0 {
+		c.servers = []string{""}
+	}
+	for _, server := range c.servers {
+		c.resolvers = append(c.resolvers, c.NewResolver(server, false))
+		c.tcpResolvers = append(c.tcpResolvers, c.NewResolver(server, true))
+	}
+}
+
+// selfTest queries the root NS set from every configured server so an
+// unreachable or refusing resolver is reported at startup, instead of only
+// showing up as every check failing
+func (c *Config) selfTest() error {
+	var failed []string
+	for i, server := range c.servers {
+		name := server
+		if name == "" {
+			name = "system resolver"
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.Global.Timeout)
+		msg := new(dns.Msg)
+		msg.SetQuestion(".", dns.TypeNS)
+		resp, err := c.resolvers[i].Exchange(ctx, msg)
+		cancel()
+
+		switch {
+		case err != nil:
+			log.Printf("Warning: DNS server %s failed the startup self-test and looks unreachable: %v", name, err)
+		case resp.Rcode != dns.RcodeSuccess:
+			log.Printf("Warning: DNS server %s failed the startup self-test: it answered %s", name, dns.RcodeToString[resp.Rcode])
+		default:
+			continue
+		}
+		failed = append(failed, name)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("DNS self-test failed for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
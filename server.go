@@ -0,0 +1,176 @@
+package dnsmonitor
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Diagnostics returns the writer that keeps recent errors and warnings for the
+// status page's diagnostics panel. Attach it to the standard logger alongside
+// the usual output.
+func Diagnostics() io.Writer {
+	return diagnostics
+}
+
+// SetListenAddr makes the web interface listen on addr, such as ":8080" or
+// "127.0.0.1:8080", instead of the configured address. The override survives
+// config reloads.
+func (c *Config) SetListenAddr(addr string) error {
+	if err := validateListenAddr(addr); err != nil {
+		return err
+	}
+	c.listenOverride = addr
+	return nil
+}
+
+// ListenAddr is the address the web interface should listen on: the
+// SetListenAddr override, else listen_addr, else all interfaces on port
+func (c *Config) ListenAddr() string {
+	switch {
+	case c.listenOverride != "":
+		return c.listenOverride
+	case c.Global.ListenAddr != "":
+		return c.Global.ListenAddr
+	}
+	return c.Global.Port
+}
+
+// validateListenAddr checks that addr is a host:port to listen on. The host may
+// be empty to listen on all interfaces.
+func validateListenAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%q is not host:port: %v", addr, err)
+	}
+	if _, err := net.LookupPort("tcp", port); err != nil || port == "" {
+		return fmt.Errorf("%q has an invalid port", addr)
+	}
+	return nil
+}
+
+// Setup connects the configured exporters and leader election and tests the
+// DNS servers. Call it once before Monitor. A failing server is only logged
+// unless strict_resolver is set.
+func (c *Config) Setup() error {
+	var err error
+	if c.Global.StatsD.Address != "" {
+		if c.statsd, err = newStatsDClient(c.Global.StatsD); err != nil {
+			return fmt.Errorf("error setting up StatsD: %v", err)
+		}
+	}
+	if c.Global.OTel.Endpoint != "" {
+		if c.otel, err = newOTelExporter(c.Global.OTel); err != nil {
+			return fmt.Errorf("error setting up OpenTelemetry: %v", err)
+		}
+	}
+
+	if c.Global.LeaderElection.LockFile != "" {
+		c.leader = newLeaderElector(c.Global.LeaderElection)
+		c.leader.campaign()
+		log.Printf("Leader election enabled, running as %s", c.Role())
+		c.leader.start()
+	}
+
+	// Catch an unreachable resolver before every check reports it as an error
+	c.setupResolvers()
+	if err := c.selfTest(); err != nil && c.Global.StrictResolver {
+		return fmt.Errorf("%v (strict_resolver is set)", err)
+	}
+	return nil
+}
+
+// Handler serves the status page, the API, /metrics and /healthz, behind the
+// configured authentication. /healthz reports 503 once ctx is cancelled, and a
+// watched template file is watched until then.
+func (c *Config) Handler(ctx context.Context) (http.Handler, error) {
+	var tmpl atomic.Pointer[template.Template]
+	parsed, err := parseStatusTemplate(c.Global.TemplateFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading status page template: %v", err)
+	}
+	tmpl.Store(parsed)
+	if c.Global.TemplateFile != "" && c.Global.TemplateWatch {
+		go watchTemplate(ctx, c.Global.TemplateFile, &tmpl)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		c.mu.RLock()
+		err := tmpl.Load().Execute(w, c.newStatusPage(r))
+		c.mu.RUnlock()
+		if err != nil {
+			log.Printf("Error rendering status page: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	mux.HandleFunc("/api/status", statusHandler(c))
+	mux.HandleFunc("/api/summary", summaryHandler(c))
+	mux.HandleFunc("POST /api/checks", createCheckHandler(c))
+	mux.HandleFunc("DELETE /api/checks/{id}", deleteCheckHandler(c))
+	mux.HandleFunc("DELETE /api/checks/{domain}/{type}", deleteCheckHandler(c))
+	mux.HandleFunc("GET /api/history/{id}", historyHandler(c))
+	mux.HandleFunc("GET /api/history/{domain}/{type}", historyHandler(c))
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/api/annotate", annotateHandler(c))
+	mux.HandleFunc("/api/baseline", baselineHandler(c))
+	mux.HandleFunc("/api/trace", traceHandler(c))
+
+	// Health endpoint for probes and load balancers. It reflects the process
+	// itself: 503 until monitoring has started and again once shutdown begins.
+	// With unhealthy_threshold set it also reports unhealthy when too many checks
+	// are failing, which usually points at this host's network rather than DNS.
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if ctx.Err() != nil {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		if !c.monitoring.Load() {
+			http.Error(w, "monitoring not running", http.StatusServiceUnavailable)
+			return
+		}
+
+		c.mu.RLock()
+		failing, total := c.failingChecks()
+		c.mu.RUnlock()
+
+		if c.Global.UnhealthyThreshold > 0 && total > 0 {
+			percent := float64(failing) / float64(total) * 100
+			if percent > c.Global.UnhealthyThreshold {
+				http.Error(w, fmt.Sprintf("unhealthy: %d of %d checks failing (%.0f%%)", failing, total, percent),
+					http.StatusServiceUnavailable)
+				return
+			}
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	return c.requireAuth(mux), nil
+}
+
+// Shutdown waits for the log lines and alerts of the last checks to go out,
+// hands over leadership and flushes OpenTelemetry. Call it once Monitor has
+// returned.
+func (c *Config) Shutdown(ctx context.Context) {
+	c.logWrites.Wait()
+	c.notifications.Wait()
+
+	// Followers mirror the leader's logs, so only resign once they are written
+	if c.leader != nil {
+		c.leader.resign()
+	}
+
+	if c.otel != nil {
+		if err := c.otel.shutdown(ctx); err != nil {
+			log.Printf("Error flushing OpenTelemetry: %v", err)
+		}
+	}
+}
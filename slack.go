@@ -0,0 +1,73 @@
+package dnsmonitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// slackNotifier posts to a Slack incoming webhook when a check starts failing
+// and when it recovers
+type slackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func newSlackNotifier(url string) *slackNotifier {
+	return &slackNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *slackNotifier) name() string { return "Slack" }
+
+func (s *slackNotifier) wants(alert Alert) bool {
+	return alert.Failed() || alert.Recovered() || alert.Propagated() || alert.SLOBurning() || alert.SLORecovered()
+}
+
+func (s *slackNotifier) send(alert Alert) error {
+	icon := ":red_circle:"
+	if alert.Recovered() || alert.Propagated() || alert.SLORecovered() {
+		icon = ":large_green_circle:"
+	}
+
+	actual := strings.Join(alert.Result.ActualResult, ", ")
+	if actual == "" {
+		actual = "(no records)"
+	}
+	text := fmt.Sprintf("%s *%s %s* is %s on %s (was %s)\n*Expected:* `%s`\n*Actual:* `%s`\n*Status:* %s",
+		icon, alert.Domain, alert.Type, alert.Current, alert.Result.Server, alert.Previous,
+		alert.Expected, actual, alert.Result.Describe())
+	if alert.SLO != nil {
+		text = fmt.Sprintf("%s *%s*\n*SLO:* %.2f%% over %s", icon, alert.Summary(), alert.SLO.Target, alert.SLO.Window)
+	}
+	if alert.Recovered() {
+		text += fmt.Sprintf("\n*Outage:* %s", alert.Outage)
+	}
+	if alert.Propagated() {
+		text += fmt.Sprintf("\n*Took:* %s", alert.Propagation)
+	}
+	if alert.Description != "" {
+		text += "\n" + alert.Description
+	}
+	if alert.Runbook != "" {
+		text += fmt.Sprintf("\n<%s|Runbook>", alert.Runbook)
+	}
+
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Slack webhook returned %s", resp.Status)
+	}
+	return nil
+}
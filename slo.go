@@ -0,0 +1,140 @@
+package dnsmonitor
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+type SLOConfig struct {
+	Target   float64       `yaml:"target"`
+	Window   time.Duration `yaml:"window"`
+	BurnRate float64       `yaml:"burn_rate"`
+}
+
+func (s *SLOConfig) validate() error {
+	if s.Target <= 0 || s.Target >= 100 {
+		return fmt.Errorf("slo target must be between 0 and 100 (exclusive), got %v", s.Target)
+	}
+	if s.Window < 0 || s.BurnRate < 0 {
+		return fmt.Errorf("slo window and burn_rate must not be negative")
+	}
+	if s.Window == 0 {
+		s.Window = time.Hour
+	}
+	if s.BurnRate == 0 {
+		s.BurnRate = 1
+	}
+	return nil
+}
+
+// successRate returns the fraction of results since the given time that passed,
+// along with the number of results considered. Results without a verdict, such
+// as unsupported record types, are not comparable and are skipped.
+func successRate(history []CheckResult, since time.Time) (float64, int) {
+	var passed, total int
+	for _, result := range history {
+		if statusClass(result.Status) == "PENDING" {
+			continue
+		}
+		// Deduplicated entries count once for every poll they stand for
+		polls := result.pollsSince(since)
+		total += polls
+		if class := statusClass(result.Status); class == "PASS" || class == "PROPAGATED" {
+			passed += polls
+		}
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(passed) / float64(total), total
+}
+
+// UptimeWindow is the share of passing results over one reporting window.
+// Percent is nil when the window has no results.
+type UptimeWindow struct {
+	Window  string   `json:"window"`
+	Percent *float64 `json:"percent"`
+}
+
+func (u UptimeWindow) String() string {
+	if u.Percent == nil {
+		return "N/A"
+	}
+	return fmt.Sprintf("%.2f%%", *u.Percent)
+}
+
+// windowLabel formats a window as whole days or hours where possible (7d, 24h)
+func windowLabel(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour && d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return d.String()
+}
+
+// Uptime reports the check's uptime over each configured window
+func (c *Config) Uptime(check *DNSCheck) []UptimeWindow {
+	now := time.Now()
+	check.historyLock.RLock()
+	defer check.historyLock.RUnlock()
+
+	uptime := make([]UptimeWindow, 0, len(c.Global.UptimeWindows))
+	for _, window := range c.Global.UptimeWindows {
+		u := UptimeWindow{Window: windowLabel(window)}
+		if rate, total := successRate(check.History, now.Add(-window)); total > 0 {
+			percent := rate * 100
+			u.Percent = &percent
+		}
+		uptime = append(uptime, u)
+	}
+	return uptime
+}
+
+// updateBurnRate recomputes how fast the check is consuming its error budget
+// over the SLO window, returning an alert when it crosses the configured burn
+// rate in either direction. Callers must hold check.historyLock.
+func (check *DNSCheck) updateBurnRate(result CheckResult) (Alert, bool) {
+	if check.SLO == nil {
+		return Alert{}, false
+	}
+
+	rate, total := successRate(check.History, result.Timestamp.Add(-check.SLO.Window))
+	if total == 0 {
+		return Alert{}, false
+	}
+
+	budget := 1 - check.SLO.Target/100
+	check.BurnRate = (1 - rate) / budget
+
+	burning := check.BurnRate > check.SLO.BurnRate
+	if burning == check.sloBurning {
+		return Alert{}, false
+	}
+	check.sloBurning = burning
+
+	alert := Alert{
+		CheckID:     check.ID(),
+		Domain:      check.Domain,
+		Type:        check.Type,
+		Expected:    check.Expected,
+		Current:     statusClass(result.Status),
+		Result:      result,
+		Description: check.Description,
+		Runbook:     check.Runbook,
+		BurnRate:    check.BurnRate,
+		SLO:         check.SLO,
+	}
+	if burning {
+		alert.Event = "slo_burn"
+		log.Printf("Warning: SLO alert for %s: error budget burning at %.1fx (threshold %.1fx, %.2f%% success over %s, target %.2f%%)",
+			check.ID(), check.BurnRate, check.SLO.BurnRate, rate*100, check.SLO.Window, check.SLO.Target)
+	} else {
+		alert.Event = "slo_recovered"
+		log.Printf("SLO recovered for %s: burn rate %.1fx is below threshold %.1fx",
+			check.ID(), check.BurnRate, check.SLO.BurnRate)
+	}
+	return alert, true
+}
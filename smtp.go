@@ -0,0 +1,83 @@
+package dnsmonitor
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host     string        `yaml:"host"`
+	Port     int           `yaml:"port"`
+	From     string        `yaml:"from"`
+	To       []string      `yaml:"to"`
+	Username string        `yaml:"username"`
+	Password string        `yaml:"password"`
+	Cooldown time.Duration `yaml:"cooldown"`
+}
+
+func (s *SMTPConfig) validate() error {
+	if s.Host == "" || s.From == "" || len(s.To) == 0 {
+		return fmt.Errorf("smtp alerts require host, from and at least one to address")
+	}
+	if s.Port == 0 {
+		s.Port = 587
+	}
+	if s.Cooldown == 0 {
+		s.Cooldown = 15 * time.Minute
+	}
+	return nil
+}
+
+// smtpNotifier emails when a check starts failing and when it recovers, each
+// at most once per cooldown for each check and server, and likewise for each
+// check when its SLO burn rate crosses the threshold
+type smtpNotifier struct {
+	config   SMTPConfig
+	cooldown *cooldown
+}
+
+func newSMTPNotifier(config SMTPConfig) *smtpNotifier {
+	return &smtpNotifier{config: config, cooldown: newCooldown(config.Cooldown)}
+}
+
+func (s *smtpNotifier) name() string { return "email" }
+
+func (s *smtpNotifier) wants(alert Alert) bool {
+	key := alert.CheckID + "/" + alert.Result.Server
+	switch {
+	case alert.Failed():
+		return s.cooldown.allow(key, alert.Result.Timestamp)
+	case alert.Recovered():
+		// Rate limited separately, so a failure email never suppresses the
+		// recovery email that follows it
+		return s.cooldown.allow(key+"/recovered", alert.Result.Timestamp)
+	case alert.Propagated():
+		return true
+	case alert.SLOBurning():
+		return s.cooldown.allow(alert.CheckID+"/slo", alert.Result.Timestamp)
+	case alert.SLORecovered():
+		return s.cooldown.allow(alert.CheckID+"/slo/recovered", alert.Result.Timestamp)
+	}
+	return false
+}
+
+func (s *smtpNotifier) send(alert Alert) error {
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: [dns-monitor] %s\r\nDate: %s\r\n\r\n%s",
+		s.config.From,
+		strings.Join(s.config.To, ", "),
+		alert.Summary(),
+		time.Now().Format(time.RFC1123Z),
+		strings.ReplaceAll(alert.Details(), "\n", "\r\n"))
+
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	return smtp.SendMail(addr, auth, s.config.From, s.config.To, []byte(msg))
+}
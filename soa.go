@@ -0,0 +1,101 @@
+package dnsmonitor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// queryDirect sends a query straight to the server for record types
+// net.Resolver cannot look up. An empty server uses the system resolver.
+// Truncated UDP answers are retried over TCP.
+func queryDirect(ctx context.Context, domain string, qtype uint16, server string, forceTCP bool) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(domain), qtype)
+	resp, err := exchangeDirect(ctx, msg, server, forceTCP)
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s on %s: %w", domain, server, err)
+	}
+	if resp.Rcode == dns.RcodeNameError {
+		return nil, &net.DNSError{Err: "no such host", Name: domain, Server: server, IsNotFound: true}
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("lookup %s on %s: %s", domain, server, dns.RcodeToString[resp.Rcode])
+	}
+	return resp, nil
+}
+
+// exchangeDirect sends msg to the server and returns the response whatever
+// its rcode. An empty server uses the system resolver.
+func exchangeDirect(ctx context.Context, msg *dns.Msg, server string, forceTCP bool) (*dns.Msg, error) {
+	if server == "" {
+		conf, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+		if err != nil || len(conf.Servers) == 0 {
+			return nil, fmt.Errorf("no system DNS server available: %v", err)
+		}
+		server = conf.Servers[0]
+	}
+
+	addr, err := serverAddr(server)
+	if err != nil {
+		return nil, err
+	}
+
+	client := new(dns.Client)
+	if forceTCP {
+		client.Net = "tcp"
+	}
+	resp, _, err := client.ExchangeContext(ctx, msg, addr)
+	if err == nil && resp.Truncated && !forceTCP {
+		resp, _, err = (&dns.Client{Net: "tcp"}).ExchangeContext(ctx, msg, addr)
+	}
+	return resp, err
+}
+
+// lookupSOA queries the SOA record of a zone directly from the server, since
+// net.Resolver has no SOA lookup
+func lookupSOA(ctx context.Context, domain, server string, forceTCP bool) (*dns.SOA, error) {
+	resp, err := queryDirect(ctx, domain, dns.TypeSOA, server, forceTCP)
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range resp.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa, nil
+		}
+	}
+	return nil, fmt.Errorf("lookup %s on %s: no SOA record (not a zone apex?)", domain, server)
+}
+
+// soaSerial extracts the serial from an SOA record formatted by rrValue
+func soaSerial(record string) (uint32, bool) {
+	fields := strings.Fields(record)
+	if len(fields) < 3 {
+		return 0, false
+	}
+	serial, err := strconv.ParseUint(fields[2], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(serial), true
+}
+
+// previousSerial returns the SOA serial from the last answer the given server
+// returned for this check
+func (check *DNSCheck) previousSerial(server string) (uint32, bool) {
+	check.historyLock.RLock()
+	defer check.historyLock.RUnlock()
+
+	for i := len(check.History) - 1; i >= 0; i-- {
+		result := check.History[i]
+		if result.Server != server || len(result.ActualResult) == 0 {
+			continue
+		}
+		return soaSerial(result.ActualResult[0])
+	}
+	return 0, false
+}
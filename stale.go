@@ -0,0 +1,57 @@
+package dnsmonitor
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// staleWatchInterval is how often checks are looked at for missing results
+const staleWatchInterval = 10 * time.Second
+
+// StaleAfter is how long a check may go without a result before it is marked
+// STALE: two intervals, plus the time a poll may take with its retries
+func (check *DNSCheck) StaleAfter() time.Duration {
+	return 2*check.Interval + check.Timeout*time.Duration(check.Retries+1)
+}
+
+// watchStale marks checks STALE while they go without results until ctx is
+// cancelled. A poll that hangs or a scheduler that stopped would otherwise
+// leave the last status, possibly PASS, on display indefinitely.
+func (c *Config) watchStale(ctx context.Context) {
+	ticker := time.NewTicker(staleWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.markStale(time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// markStale sets the status of every scheduled check that has had no result
+// for longer than StaleAfter, counting from when it was scheduled if that is
+// later. The next result replaces the status as usual.
+func (c *Config) markStale(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, check := range c.Checks {
+		if check.Status == "STALE" {
+			continue
+		}
+		since, ok := c.scheduler.since(check)
+		if !ok {
+			continue
+		}
+		if check.LastCheck.After(since) {
+			since = check.LastCheck
+		}
+		if now.Sub(since) > check.StaleAfter() {
+			log.Printf("Warning: Check %s has had no result since %s; marking it STALE", check.ID(), since.Format(time.RFC3339))
+			check.Status = "STALE"
+		}
+	}
+}
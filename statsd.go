@@ -0,0 +1,93 @@
+package dnsmonitor
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+type StatsDConfig struct {
+	Address   string   `yaml:"address"`
+	Prefix    string   `yaml:"prefix"`
+	Tags      []string `yaml:"tags"`
+	DogStatsD bool     `yaml:"dogstatsd"`
+}
+
+type statsdClient struct {
+	conn      net.Conn
+	prefix    string
+	tags      []string
+	dogstatsd bool
+}
+
+func newStatsDClient(cfg StatsDConfig) (*statsdClient, error) {
+	conn, err := net.Dial("udp", cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to StatsD at %s: %v", cfg.Address, err)
+	}
+
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = "dns_monitor"
+	}
+
+	return &statsdClient{
+		conn:      conn,
+		prefix:    strings.TrimSuffix(prefix, "."),
+		tags:      cfg.Tags,
+		dogstatsd: cfg.DogStatsD,
+	}, nil
+}
+
+// sendCheckResult pushes the status gauge and latency timer for a single poll,
+// plus the SLO burn rate when the check has an SLO
+func (s *statsdClient) sendCheckResult(check *DNSCheck, result CheckResult, burnRate float64) {
+	status := 0
+	if statusClass(result.Status) == "PASS" {
+		status = 1
+	}
+
+	var lines []string
+	if s.dogstatsd {
+		tags := append([]string{
+			"check:" + check.ID(),
+			"domain:" + check.Domain,
+			"type:" + check.Type,
+			"server:" + result.Server,
+		}, s.tags...)
+		suffix := "|#" + strings.Join(tags, ",")
+		lines = []string{
+			fmt.Sprintf("%s.check.status:%d|g%s", s.prefix, status, suffix),
+			fmt.Sprintf("%s.check.latency:%d|ms%s", s.prefix, result.Duration.Milliseconds(), suffix),
+		}
+		if check.SLO != nil {
+			lines = append(lines, fmt.Sprintf("%s.check.burn_rate:%g|g%s", s.prefix, burnRate, suffix))
+		}
+	} else {
+		// Plain StatsD has no tags, so the labels become part of the metric name
+		id := sanitizeMetricPart(check.Domain) + "." + sanitizeMetricPart(check.Type)
+		if check.Name != "" {
+			id = sanitizeMetricPart(check.Name)
+		}
+		name := fmt.Sprintf("%s.check.%s.%s", s.prefix, id, sanitizeMetricPart(result.Server))
+		lines = []string{
+			fmt.Sprintf("%s.status:%d|g", name, status),
+			fmt.Sprintf("%s.latency:%d|ms", name, result.Duration.Milliseconds()),
+		}
+		if check.SLO != nil {
+			lines = append(lines, fmt.Sprintf("%s.burn_rate:%g|g", name, burnRate))
+		}
+	}
+
+	if _, err := s.conn.Write([]byte(strings.Join(lines, "\n"))); err != nil {
+		log.Printf("Error sending metrics to StatsD: %v", err)
+	}
+}
+
+func sanitizeMetricPart(s string) string {
+	if s == "" {
+		return "default"
+	}
+	return strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", "#", "_").Replace(s)
+}
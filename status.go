@@ -0,0 +1,157 @@
+package dnsmonitor
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// CheckStatus is the JSON view of a check served by /api/status
+type CheckStatus struct {
+	ID           string         `json:"id"`
+	Name         string         `json:"name,omitempty"`
+	Domain       string         `json:"domain"`
+	Type         string         `json:"type"`
+	Expected     string         `json:"expected"`
+	Description  string         `json:"description,omitempty"`
+	Runbook      string         `json:"runbook,omitempty"`
+	Labels       Labels         `json:"labels,omitempty"`
+	Maintenance  bool           `json:"maintenance,omitempty"`
+	Status       string         `json:"status"`
+	State        string         `json:"state"`
+	LastCheck    time.Time      `json:"last_check"`
+	NextRun      *time.Time     `json:"next_run,omitempty"`
+	Interval     string         `json:"interval"`
+	LatestResult *CheckResult   `json:"latest_result"`
+	Uptime       []UptimeWindow `json:"uptime"`
+}
+
+// statusSnapshot copies the current state of every check matching the filter.
+// Callers must hold config.mu.
+func (c *Config) statusSnapshot(filter labelFilter) []CheckStatus {
+	statuses := make([]CheckStatus, 0, len(c.Checks))
+	for _, check := range c.Checks {
+		if filter.matches(check) {
+			statuses = append(statuses, c.checkStatus(check))
+		}
+	}
+	return statuses
+}
+
+// checkStatus copies the current state of one check. Callers must hold config.mu.
+func (c *Config) checkStatus(check *DNSCheck) CheckStatus {
+	status := CheckStatus{
+		ID:          check.ID(),
+		Name:        check.Name,
+		Domain:      check.Domain,
+		Type:        check.Type,
+		Expected:    check.Expected,
+		Description: check.Description,
+		Runbook:     check.Runbook,
+		Labels:      check.Labels,
+		Status:      check.Status,
+		State:       statusClass(check.Status),
+		LastCheck:   check.LastCheck,
+		Uptime:      c.Uptime(check),
+	}
+
+	status.Maintenance = c.Maintenance(check) != nil
+	status.Interval = check.Interval.String()
+	if next := c.NextRun(check); !next.IsZero() {
+		status.NextRun = &next
+	}
+
+	status.LatestResult = check.Latest()
+	return status
+}
+
+// Summary counts checks by how healthy they currently are. Worst is the status
+// class of the most severe bucket that is not empty, used to color the banner.
+type Summary struct {
+	Total    int    `json:"total"`
+	Passing  int    `json:"passing"`
+	Failing  int    `json:"failing"`
+	Errors   int    `json:"errors"`
+	Pending  int    `json:"pending"`
+	Disabled int    `json:"disabled"`
+	Worst    string `json:"worst"`
+}
+
+// summarize buckets checks by their current status. Lookups that did not get
+// an answer count as errors; wrong or missing answers count as failing.
+func summarize(checks []*DNSCheck) Summary {
+	var summary Summary
+	for _, check := range checks {
+		summary.Total++
+		switch class := statusClass(check.Status); class {
+		case "PENDING", "PROPAGATING":
+			summary.Pending++
+		case "DISABLED":
+			summary.Disabled++
+		case "ERROR", "TIMEOUT", "TRANSIENT", "STALE":
+			summary.Errors++
+		case "MISMATCH", "DRIFT":
+			summary.Failing++
+		default:
+			if failingClass(class) {
+				summary.Failing++
+			} else {
+				summary.Passing++
+			}
+		}
+	}
+
+	switch {
+	case summary.Failing > 0:
+		summary.Worst = "FAIL"
+	case summary.Errors > 0:
+		summary.Worst = "ERROR"
+	case summary.Pending > 0 || summary.Passing == 0:
+		summary.Worst = "PENDING"
+	default:
+		summary.Worst = "PASS"
+	}
+	return summary
+}
+
+// Summary counts the checks on the status page by health
+func (p *statusPage) Summary() Summary {
+	return summarize(p.Checks)
+}
+
+// summaryHandler serves the health counts of all checks, or of those matching
+// ?label=, as JSON
+func summaryHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter := labelFilterFromRequest(r)
+		var checks []*DNSCheck
+		config.mu.RLock()
+		for _, check := range config.Checks {
+			if filter.matches(check) {
+				checks = append(checks, check)
+			}
+		}
+		summary := summarize(checks)
+		config.mu.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(summary); err != nil {
+			log.Printf("Error encoding summary response: %v", err)
+		}
+	}
+}
+
+// statusHandler serves the current state of all checks as JSON
+func statusHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config.mu.RLock()
+		statuses := config.statusSnapshot(labelFilterFromRequest(r))
+		config.mu.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(statuses); err != nil {
+			log.Printf("Error encoding status response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package dnsmonitor
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// expandSubdomains replaces each check that lists subdomains with one check per
+// prefix under its domain, sharing the rest of its settings. "@" stands for the
+// domain itself. Named checks get the prefix appended to their name.
+func expandSubdomains(checks []*DNSCheck) []*DNSCheck {
+	var expanded []*DNSCheck
+	for _, check := range checks {
+		if len(check.Subdomains) == 0 {
+			expanded = append(expanded, check)
+			continue
+		}
+		for _, prefix := range check.Subdomains {
+			sub := check.cloneSettings()
+			sub.Subdomains = nil
+			sub.source = check.source
+			if prefix != "@" && prefix != "" {
+				sub.Domain = prefix + "." + check.Domain
+				if sub.Name != "" {
+					sub.Name = fmt.Sprintf("%s-%s", check.Name, prefix)
+				}
+			}
+			expanded = append(expanded, sub)
+		}
+	}
+	return expanded
+}
+
+// cloneSettings copies a check's configured (exported) fields into a new check
+// with no runtime state
+func (check *DNSCheck) cloneSettings() *DNSCheck {
+	clone := new(DNSCheck)
+	src, dst := reflect.ValueOf(check).Elem(), reflect.ValueOf(clone).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		if src.Type().Field(i).IsExported() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+	return clone
+}
@@ -0,0 +1,80 @@
+package dnsmonitor
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// templateWatchInterval is how often a watched template file is checked for changes
+const templateWatchInterval = 2 * time.Second
+
+// templateFuncs are available to the status page template. Those reading a
+// check's history take the check rather than its History, so they can hold
+// its lock while polls append to it.
+var templateFuncs = template.FuncMap{
+	"contains":    contains,
+	"lastCheck":   (*DNSCheck).Latest,
+	"resultDiff":  (*DNSCheck).Diff,
+	"statusClass": statusClass,
+}
+
+// parseStatusTemplate parses the status page template from file, or the
+// embedded default when file is empty
+func parseStatusTemplate(file string) (*template.Template, error) {
+	text := statusPageHTML
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading template file: %v", err)
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New("status").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// watchTemplate re-parses the template file whenever its modification time
+// changes until ctx is cancelled. A template that fails to parse is logged and
+// the previous one keeps serving.
+func watchTemplate(ctx context.Context, file string, tmpl *atomic.Pointer[template.Template]) {
+	var modTime time.Time
+	if info, err := os.Stat(file); err == nil {
+		modTime = info.ModTime()
+	}
+
+	ticker := time.NewTicker(templateWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			log.Printf("Warning: Failed to check template file %s: %v", file, err)
+			continue
+		}
+		if info.ModTime().Equal(modTime) {
+			continue
+		}
+		modTime = info.ModTime()
+
+		parsed, err := parseStatusTemplate(file)
+		if err != nil {
+			log.Printf("Error reloading template file %s: %v", file, err)
+			continue
+		}
+		tmpl.Store(parsed)
+		log.Printf("Reloaded template file %s", file)
+	}
+}
@@ -0,0 +1,126 @@
+package dnsmonitor
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseTemplates returns the named check templates under the config file's
+// top-level templates key. A template holds any check fields; checks name it
+// with template: and inherit the fields they leave out.
+func parseTemplates(doc *yaml.Node) (map[string]*yaml.Node, error) {
+	section := mappingValue(documentRoot(doc), "templates")
+	if section == nil {
+		return nil, nil
+	}
+	if section.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: templates must map template names to check fields", section.Line)
+	}
+
+	templates := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(section.Content); i += 2 {
+		name, template := section.Content[i].Value, resolveAlias(section.Content[i+1])
+		if template.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("line %d: template %q must be a mapping of check fields", template.Line, name)
+		}
+		if mappingValue(template, "template") != nil {
+			return nil, fmt.Errorf("line %d: template %q cannot itself use a template", template.Line, name)
+		}
+		templates[name] = template
+	}
+	return templates, nil
+}
+
+// applyTemplates merges templates into the checks of a config or included
+// file, in place
+func applyTemplates(doc *yaml.Node, templates map[string]*yaml.Node) error {
+	checks := mappingValue(documentRoot(doc), "checks")
+	if checks == nil || checks.Kind != yaml.SequenceNode {
+		// Left for decoding to report
+		return nil
+	}
+	for i, check := range checks.Content {
+		merged, err := applyTemplate(check, templates)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", check.Line, err)
+		}
+		checks.Content[i] = merged
+	}
+	return nil
+}
+
+// applyTemplate returns a check with the fields it leaves out taken from the
+// template it names, or the check unchanged when it names none
+func applyTemplate(check *yaml.Node, templates map[string]*yaml.Node) (*yaml.Node, error) {
+	check = resolveAlias(check)
+	name := mappingValue(check, "template")
+	if name == nil {
+		return check, nil
+	}
+	template, ok := templates[name.Value]
+	if !ok {
+		return nil, fmt.Errorf("unknown template %q", name.Value)
+	}
+	return mergeTemplate(template, check), nil
+}
+
+// mergeTemplate returns check with the fields it leaves out taken from
+// template. Nested mappings such as labels are merged the same way; anything
+// else the check sets replaces the template's value. Neither node is modified,
+// since YAML anchors may share them between checks.
+func mergeTemplate(template, check *yaml.Node) *yaml.Node {
+	template, check = resolveAlias(template), resolveAlias(check)
+	if template.Kind != yaml.MappingNode || check.Kind != yaml.MappingNode {
+		return check
+	}
+
+	merged := *check
+	merged.Content = append([]*yaml.Node(nil), check.Content...)
+	for i := 0; i+1 < len(template.Content); i += 2 {
+		key, value := template.Content[i], template.Content[i+1]
+		if j := mappingIndex(&merged, key.Value); j >= 0 {
+			merged.Content[j+1] = mergeTemplate(value, merged.Content[j+1])
+			continue
+		}
+		merged.Content = append(merged.Content, key, value)
+	}
+	return &merged
+}
+
+// documentRoot returns the top-level node of a parsed YAML document
+func documentRoot(doc *yaml.Node) *yaml.Node {
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		return resolveAlias(doc.Content[0])
+	}
+	return resolveAlias(doc)
+}
+
+// resolveAlias follows a YAML alias (*name) to the node it refers to
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// mappingValue returns the value of key in a YAML mapping, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if i := mappingIndex(node, key); i >= 0 {
+		return resolveAlias(node.Content[i+1])
+	}
+	return nil
+}
+
+// mappingIndex returns the position of key in a YAML mapping's content, or -1
+func mappingIndex(node *yaml.Node, key string) int {
+	if node.Kind != yaml.MappingNode {
+		return -1
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,69 @@
+package dnsmonitor
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// loadTLS checks the TLS settings and loads the certificate, so a missing
+// file or a key that does not match the certificate stops startup
+func (c *Config) loadTLS() error {
+	if (c.Global.TLSCert == "") != (c.Global.TLSKey == "") {
+		return fmt.Errorf("tls_cert and tls_key must be set together")
+	}
+	if c.Global.HTTPRedirectAddr != "" {
+		if c.Global.TLSCert == "" {
+			return fmt.Errorf("http_redirect_addr requires tls_cert and tls_key")
+		}
+		if err := validateListenAddr(c.Global.HTTPRedirectAddr); err != nil {
+			return fmt.Errorf("invalid http_redirect_addr: %v", err)
+		}
+	}
+	if c.Global.TLSCert == "" {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.Global.TLSCert, c.Global.TLSKey)
+	if err != nil {
+		return fmt.Errorf("error loading tls_cert and tls_key: %v", err)
+	}
+	c.tlsCert.Store(&cert)
+	return nil
+}
+
+// TLSConfig returns the TLS settings for the web interface, or nil to serve
+// plain HTTP. The certificate is looked up per connection, so one renewed on
+// disk is picked up by the next config reload.
+func (c *Config) TLSConfig() *tls.Config {
+	if c.tlsCert.Load() == nil {
+		return nil
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return c.tlsCert.Load(), nil
+		},
+	}
+}
+
+// RedirectHandler redirects every request to the same URL over HTTPS, on the
+// port the web interface listens on. Serve it on http_redirect_addr.
+func (c *Config) RedirectHandler() http.Handler {
+	_, port, _ := net.SplitHostPort(c.ListenAddr())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if port != "443" && port != "https" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
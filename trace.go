@@ -0,0 +1,155 @@
+package dnsmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// rootServers are the IPv4 addresses of a.root-servers.net through e.root-servers.net
+var rootServers = []string{"198.41.0.4", "170.247.170.2", "192.33.4.12", "199.7.91.13", "192.203.230.10"}
+
+const maxTraceSteps = 20
+
+type TraceStep struct {
+	Server    string   `json:"server"`
+	Zone      string   `json:"zone,omitempty"`
+	Rcode     string   `json:"rcode,omitempty"`
+	Answer    []string `json:"answer,omitempty"`
+	Referral  []string `json:"referral,omitempty"`
+	Duration  string   `json:"duration"`
+	Error     string   `json:"error,omitempty"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+// traceQuery iteratively resolves domain starting at the root servers,
+// recording every delegation step like dig +trace. Name servers without glue
+// are resolved through the given resolver. Cancelling ctx, such as when the
+// client disconnects, stops the trace after the steps so far.
+func traceQuery(ctx context.Context, domain string, qtype uint16, resolver Resolver) []TraceStep {
+	client := &dns.Client{Timeout: 5 * time.Second}
+	servers := rootServers
+	zone := "."
+
+	var steps []TraceStep
+	for len(steps) < maxTraceSteps {
+		if ctx.Err() != nil {
+			return steps
+		}
+		server := servers[0]
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(domain), qtype)
+		msg.RecursionDesired = false
+
+		step := TraceStep{Server: server, Zone: zone}
+		addr, err := serverAddr(server)
+		var resp *dns.Msg
+		var rtt time.Duration
+		if err == nil {
+			resp, rtt, err = client.ExchangeContext(ctx, msg, addr)
+		}
+		if err == nil && resp.Truncated {
+			step.Truncated = true
+			tcp := &dns.Client{Net: "tcp", Timeout: client.Timeout}
+			resp, rtt, err = tcp.ExchangeContext(ctx, msg, addr)
+		}
+		step.Duration = rtt.String()
+		if err != nil {
+			step.Error = err.Error()
+			steps = append(steps, step)
+			// Try the next server for the same zone before giving up
+			if len(servers) > 1 && ctx.Err() == nil {
+				servers = servers[1:]
+				continue
+			}
+			return steps
+		}
+
+		step.Rcode = dns.RcodeToString[resp.Rcode]
+		for _, rr := range resp.Answer {
+			step.Answer = append(step.Answer, rr.String())
+		}
+
+		// Collect the delegation and any glue addresses
+		var nsNames []string
+		glue := make(map[string][]string)
+		for _, rr := range resp.Ns {
+			if ns, ok := rr.(*dns.NS); ok {
+				nsNames = append(nsNames, ns.Ns)
+				zone = ns.Hdr.Name
+				step.Referral = append(step.Referral, ns.Ns)
+			}
+		}
+		for _, rr := range resp.Extra {
+			if a, ok := rr.(*dns.A); ok {
+				glue[strings.ToLower(a.Hdr.Name)] = append(glue[strings.ToLower(a.Hdr.Name)], a.A.String())
+			}
+		}
+		steps = append(steps, step)
+
+		if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) > 0 || len(nsNames) == 0 {
+			return steps
+		}
+
+		var next []string
+		for _, name := range nsNames {
+			next = append(next, glue[strings.ToLower(name)]...)
+		}
+		if len(next) == 0 {
+			for _, name := range nsNames {
+				ips, err := resolver.LookupIP(ctx, "ip4", name)
+				if err == nil {
+					for _, ip := range ips {
+						next = append(next, ip.String())
+					}
+					break
+				}
+			}
+		}
+		if len(next) == 0 {
+			steps = append(steps, TraceStep{Zone: zone, Error: "no reachable name server address for delegation"})
+			return steps
+		}
+		servers = next
+	}
+
+	return append(steps, TraceStep{Error: fmt.Sprintf("gave up after %d steps", maxTraceSteps)})
+}
+
+// traceHandler serves GET /api/trace?name=... (or ?domain=...&type=...) for configured checks
+func traceHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config.mu.RLock()
+		check, lookupErr := config.lookupCheck(r)
+		// PTR checks trace the reverse name and CHAIN checks the A records
+		var domain, recordType string
+		var qtype uint16
+		if check != nil {
+			domain, qtype = directQuestion(check)
+			recordType = check.Type
+		}
+		// Built per request so it follows the current configuration
+		resolver := config.NewResolver(config.Global.DNSServer, false)
+		config.mu.RUnlock()
+		if lookupErr != nil {
+			http.Error(w, lookupErr.Error(), lookupErr.status)
+			return
+		}
+
+		if qtype == 0 {
+			http.Error(w, fmt.Sprintf("cannot trace record type %s", recordType), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(traceQuery(r.Context(), domain, qtype, resolver)); err != nil {
+			log.Printf("Error encoding trace response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package dnsmonitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// recordTTLs asks the server for the check's records directly, since
+// net.Resolver drops TTLs, and returns the TTL of each answer record keyed
+// by the record as it appears in the check's results
+func (check *DNSCheck) recordTTLs(ctx context.Context, resolver Resolver) (map[string]uint32, error) {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	name, qtype := directQuestion(check)
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	check.setClass(msg)
+	check.addClientSubnet(msg)
+	resp, err := resolver.Exchange(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("server answered %s", dns.RcodeToString[resp.Rcode])
+	}
+
+	ttls := make(map[string]uint32)
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype != qtype {
+			continue
+		}
+		if value, ok := rrValue(rr); ok {
+			ttls[value] = rr.Header().Ttl
+		}
+	}
+	return ttls, nil
+}
+
+// TTLRange describes the allowed record TTLs, or "" when unrestricted
+func (check *DNSCheck) TTLRange() string {
+	switch {
+	case check.TTLMin > 0 && check.TTLMax > 0:
+		return fmt.Sprintf("%s-%s", check.TTLMin, check.TTLMax)
+	case check.TTLMin > 0:
+		return fmt.Sprintf("at least %s", check.TTLMin)
+	case check.TTLMax > 0:
+		return fmt.Sprintf("at most %s", check.TTLMax)
+	}
+	return ""
+}
+
+// checkTTLs returns a description of the first record whose TTL is outside the
+// check's range, or "" when all are within it. Records without a known TTL,
+// such as the hops of a CHAIN check, are skipped.
+func (check *DNSCheck) checkTTLs(records []string, ttls map[string]uint32) string {
+	for _, record := range records {
+		seconds, ok := ttls[record]
+		if !ok {
+			continue
+		}
+		ttl := time.Duration(seconds) * time.Second
+		if ttl < check.TTLMin || (check.TTLMax > 0 && ttl > check.TTLMax) {
+			return fmt.Sprintf("%s has TTL %s, want %s", record, ttl, check.TTLRange())
+		}
+	}
+	return ""
+}
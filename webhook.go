@@ -0,0 +1,118 @@
+package dnsmonitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type WebhookConfig struct {
+	URL      string            `yaml:"url"`
+	Method   string            `yaml:"method"`
+	Headers  map[string]string `yaml:"headers"`
+	Template string            `yaml:"template"`
+	Retries  int               `yaml:"retries"`
+}
+
+func (w *WebhookConfig) validate() error {
+	if w.URL == "" {
+		return fmt.Errorf("webhook requires a url")
+	}
+	if w.Method == "" {
+		w.Method = http.MethodPost
+	}
+	w.Method = strings.ToUpper(w.Method)
+	if w.Retries < 0 {
+		return fmt.Errorf("webhook retries must not be negative")
+	}
+	if w.Retries == 0 {
+		w.Retries = 2
+	}
+	return nil
+}
+
+// webhookNotifier sends every status transition to a user-defined endpoint,
+// either as the JSON-encoded Alert or rendered through a payload template
+type webhookNotifier struct {
+	config   WebhookConfig
+	template *template.Template
+	client   *http.Client
+}
+
+func newWebhookNotifier(config WebhookConfig) (*webhookNotifier, error) {
+	w := &webhookNotifier{config: config, client: &http.Client{Timeout: 10 * time.Second}}
+	if config.Template != "" {
+		tmpl, err := template.New("webhook").Funcs(template.FuncMap{
+			// json renders a value as a JSON literal, e.g. {{json .Result.Status}}
+			"json": func(v interface{}) (string, error) {
+				b, err := json.Marshal(v)
+				return string(b), err
+			},
+		}).Parse(config.Template)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing webhook template: %v", err)
+		}
+		w.template = tmpl
+	}
+	return w, nil
+}
+
+func (w *webhookNotifier) name() string { return "webhook" }
+
+func (w *webhookNotifier) wants(alert Alert) bool { return true }
+
+func (w *webhookNotifier) payload(alert Alert) ([]byte, error) {
+	if w.template == nil {
+		return json.Marshal(alert)
+	}
+	var buf bytes.Buffer
+	if err := w.template.Execute(&buf, alert); err != nil {
+		return nil, fmt.Errorf("error rendering webhook template: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// send delivers the alert, retrying failed deliveries with a growing backoff
+func (w *webhookNotifier) send(alert Alert) error {
+	body, err := w.payload(alert)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = w.deliver(body)
+		if err == nil || attempt >= w.config.Retries {
+			return err
+		}
+		log.Printf("Warning: webhook delivery for %s failed (attempt %d of %d): %v",
+			alert.CheckID, attempt+1, w.config.Retries+1, err)
+		time.Sleep(time.Duration(attempt+1) * time.Second)
+	}
+}
+
+func (w *webhookNotifier) deliver(body []byte) error {
+	req, err := http.NewRequest(w.config.Method, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
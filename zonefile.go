@@ -0,0 +1,72 @@
+package dnsmonitor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// loadZoneFile parses a golden zone file into record values keyed by
+// "name/TYPE", formatted the same way PerformDNSCheck reports live answers
+func loadZoneFile(filename, origin string) (map[string][]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening zone file: %v", err)
+	}
+	defer f.Close()
+
+	if origin != "" {
+		origin = dns.Fqdn(origin)
+	}
+
+	zone := make(map[string][]string)
+	parser := dns.NewZoneParser(f, origin, filename)
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		value, supported := rrValue(rr)
+		if !supported {
+			continue
+		}
+
+		key := zoneKey(rr.Header().Name, dns.TypeToString[rr.Header().Rrtype])
+		zone[key] = append(zone[key], value)
+	}
+	if err := parser.Err(); err != nil {
+		return nil, fmt.Errorf("error parsing zone file: %v", err)
+	}
+
+	return zone, nil
+}
+
+// rrValue formats a resource record's data the way PerformDNSCheck reports it.
+// It returns false for record types that checks do not support.
+func rrValue(rr dns.RR) (string, bool) {
+	switch record := rr.(type) {
+	case *dns.A:
+		return record.A.String(), true
+	case *dns.CNAME:
+		return record.Target, true
+	case *dns.NS:
+		return record.Ns, true
+	case *dns.MX:
+		return record.Mx, true
+	case *dns.SOA:
+		return fmt.Sprintf("%s %s %d %d %d %d %d", record.Ns, record.Mbox, record.Serial,
+			record.Refresh, record.Retry, record.Expire, record.Minttl), true
+	case *dns.PTR:
+		return record.Ptr, true
+	case *dns.SRV:
+		return fmt.Sprintf("%d %d %d %s", record.Priority, record.Weight, record.Port, record.Target), true
+	case *dns.CAA:
+		return fmt.Sprintf("%d %s %s", record.Flag, record.Tag, record.Value), true
+	case *dns.TXT:
+		// net.Resolver joins the character-strings of a TXT record
+		return strings.Join(record.Txt, ""), true
+	}
+	return "", false
+}
+
+func zoneKey(domain, recordType string) string {
+	return strings.ToLower(dns.Fqdn(domain)) + "/" + strings.ToUpper(recordType)
+}